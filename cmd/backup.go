@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"digital-community/internal/backup"
+	"digital-community/internal/config"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const backupUsage = `usage: server backup [-dir DIR] [-keep N]
+
+Writes DIR/backup-<timestamp>.tar.gz (default BACKUP_DIR) with a snapshot of
+the SQLite database and the upload directory. Safe to run while the server is
+up. With -keep only the newest N archives in DIR are kept.`
+
+const restoreUsage = `usage: server restore [-no-safety-backup] <archive>
+
+Replaces the SQLite database and the upload directory with a backup archive.
+Stop the server first. The archive is extracted and validated before anything
+is replaced, and the current data is backed up to BACKUP_DIR unless
+-no-safety-backup is given.`
+
+func runBackup(cfg *config.Config, args []string) error {
+	fs := newFlagSet("backup", backupUsage)
+	dir := fs.String("dir", cfg.BackupDir, "directory to write the archive to")
+	keep := fs.Int("keep", 0, "prune DIR to the newest N archives (0 keeps all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError(backupUsage)
+	}
+
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	path, err := backup.Create(ctx, db, cfg.UploadRoot, *dir)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+
+	removed, err := backup.Prune(*dir, *keep)
+	for _, name := range removed {
+		fmt.Printf("removed %s\n", name)
+	}
+	return err
+}
+
+func runRestore(cfg *config.Config, args []string) error {
+	fs := newFlagSet("restore", restoreUsage)
+	noSafety := fs.Bool("no-safety-backup", false, "skip backing up the current data first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(restoreUsage)
+	}
+	if cfg.DBDriver != "" && cfg.DBDriver != "sqlite" && cfg.DBDriver != "sqlite3" {
+		return fmt.Errorf("restore only supports sqlite, not %s", cfg.DBDriver)
+	}
+	if cfg.DBDSN != "" {
+		return fmt.Errorf("restore needs the database file in DB_PATH, not DB_DSN")
+	}
+
+	staged, err := backup.Stage(fs.Arg(0), cfg.DBPath, cfg.UploadRoot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("archive ok: created %s, schema version %d, %d upload files\n",
+		staged.Manifest.CreatedAt.Format("2006-01-02 15:04:05"), staged.Manifest.SchemaVersion, staged.Manifest.UploadFiles)
+
+	if _, statErr := os.Stat(cfg.DBPath); !*noSafety && statErr == nil {
+		path, err := safetyBackup(cfg)
+		if err != nil {
+			staged.Discard()
+			return fmt.Errorf("safety backup: %w", err)
+		}
+		fmt.Printf("current data saved to %s\n", path)
+	}
+
+	if err := staged.Commit(); err != nil {
+		return fmt.Errorf("restore interrupted, recover from the safety backup: %w", err)
+	}
+	fmt.Println("restore complete")
+	return nil
+}
+
+func safetyBackup(cfg *config.Config) (string, error) {
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer config.CloseDB(db)
+	return backup.Create(context.Background(), db, cfg.UploadRoot, cfg.BackupDir)
+}
@@ -2,32 +2,96 @@ package main
 
 import (
 	"digital-community/internal/config"
-	"digital-community/internal/handlers"
-	"digital-community/internal/router"
+	"digital-community/internal/logging"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
+const usage = `usage: server [flags] [command]
+
+Settings come from defaults, the YAML file given by -config or CONFIG_FILE,
+environment variables and flags, each overriding the previous. Run
+"server -h" to list the flags.
+
+commands:
+  serve        start the HTTP server (default)
+  migrate      manage database schema migrations
+  seed         load a fixture set into the database
+  user         create admins and reset passwords
+  thumbnails   rebuild image thumbnails
+  backup       archive the database and uploads
+  restore      replace the database and uploads from a backup archive
+
+Run "server <command> -h" for command options.`
+
 func main() {
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	cfg := config.Load()
-
-	if err := config.InitDB(cfg.DBPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(usage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	handlers.StartThumbnailWarmup()
+	logging.Setup(cfg.LogLevel)
 
-	r := router.Setup()
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
 
-	addr := fmt.Sprintf(":%s", cfg.ServerPort)
-	log.Printf("Server starting on %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	switch command {
+	case "serve":
+		err = runServe(cfg)
+	case "migrate":
+		err = runMigrate(cfg, args)
+	case "seed":
+		err = runSeed(cfg, args)
+	case "user":
+		err = runUser(cfg, args)
+	case "thumbnails":
+		err = runThumbnails(cfg, args)
+	case "backup":
+		err = runBackup(cfg, args)
+	case "restore":
+		err = runRestore(cfg, args)
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, usageErr)
+		os.Exit(2)
+	default:
+		slog.Error(command+" failed", "error", err)
+		os.Exit(1)
 	}
 }
+
+// usageError is printed verbatim instead of being logged as a failure.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// newFlagSet returns a flag set that prints usage on -h or a bad flag.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), usage) }
+	return fs
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"digital-community/internal/config"
+	"digital-community/internal/migrations"
+	"fmt"
+	"strconv"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up [version]   apply pending migrations (up to version, default latest)
+  down [-force] [steps]
+                 roll back the last steps migrations (default 1); -force is
+                 required to roll back the baseline, which drops every table
+  status         list migrations and whether they are applied`
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError(migrateUsage)
+	}
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	switch args[0] {
+	case "up":
+		target, err := optionalInt(args[1:], 0)
+		if err != nil {
+			return err
+		}
+		ran, err := migrations.Up(db, target)
+		for _, m := range ran {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		fs := newFlagSet("migrate down", migrateUsage)
+		force := fs.Bool("force", false, "allow rolling back migrations that delete data")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		steps, err := optionalInt(fs.Args(), 1)
+		if err != nil {
+			return err
+		}
+		reverted, err := migrations.Down(db, steps, *force)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+	case "status":
+		list, err := migrations.List(db)
+		if err != nil {
+			return err
+		}
+		for _, st := range list {
+			state := "pending"
+			if st.Unsupported && !st.Applied {
+				state = "not supported by this build or database"
+			} else if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", st.Version, st.Name, state)
+		}
+	default:
+		return usageError(migrateUsage)
+	}
+	return nil
+}
+
+func optionalInt(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", args[0])
+	}
+	return n, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"digital-community/internal/config"
+	"digital-community/internal/seed"
+	"fmt"
+	"slices"
+)
+
+var seedUsage = `usage: server seed [set]
+
+sets (default ` + seed.SetDemo + `):
+  ` + seed.SetEmpty + `       lookup data only (press and activity categories)
+  ` + seed.SetDemo + `        test01/123456 admin account and sample content
+  ` + seed.SetLoadTest + `   demo plus bulk users, news, activities and posts
+
+Sets are idempotent: rerunning one only adds what is missing.`
+
+func runSeed(cfg *config.Config, args []string) error {
+	set := seed.SetDemo
+	if len(args) > 0 {
+		set = args[0]
+	}
+	if len(args) > 1 || !slices.Contains(seed.Sets(), set) {
+		return usageError(seedUsage)
+	}
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	if err := seed.Run(db, set, cfg.UploadRoot); err != nil {
+		return fmt.Errorf("seed %s: %w", set, err)
+	}
+	fmt.Printf("seeded %s\n", set)
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"digital-community/internal/backup"
+	"digital-community/internal/config"
+	"digital-community/internal/handlers"
+	"digital-community/internal/metrics"
+	"digital-community/internal/middleware"
+	"digital-community/internal/router"
+	"digital-community/internal/seed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func runServe(cfg *config.Config) error {
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		return fmt.Errorf("initialize database: %w", err)
+	}
+	defer func() {
+		if err := config.CloseDB(db); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
+	}()
+	// Created here rather than by the readiness probe, which only checks it.
+	if err := os.MkdirAll(cfg.UploadRoot, 0755); err != nil {
+		return fmt.Errorf("create upload directory: %w", err)
+	}
+	if cfg.AutoSeed {
+		if err := seed.Run(db, seed.SetDemo, cfg.UploadRoot); err != nil {
+			return fmt.Errorf("seed demo data: %w", err)
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := metrics.RegisterDB(sqlDB, cfg.DBDriver); err != nil {
+			slog.Warn("failed to register database metrics", "error", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	h, err := handlers.New(handlers.Deps{DB: db, Config: cfg})
+	if err != nil {
+		return fmt.Errorf("configure handlers: %w", err)
+	}
+	warmupDone := h.Media.StartThumbnailWarmup(ctx)
+	var backupDone, purgeDone <-chan struct{}
+	if cfg.BackupInterval > 0 {
+		backupDone = backup.StartScheduler(ctx, db, cfg.UploadRoot, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+	}
+	if cfg.SessionPurgeInterval > 0 {
+		purgeDone = h.Sessions.StartPurger(ctx, cfg.SessionPurgeInterval)
+	}
+
+	middleware.RegisterFieldNames()
+	r := router.Setup(cfg, h)
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var runErr error
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			runErr = fmt.Errorf("start server: %w", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining requests", "timeout", cfg.ShutdownTimeout.String())
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown did not complete", "error", err)
+	}
+
+	select {
+	case <-warmupDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("thumbnail warmup still running at shutdown deadline")
+	}
+	if backupDone != nil {
+		select {
+		case <-backupDone:
+		case <-shutdownCtx.Done():
+			slog.Warn("scheduled backup still running at shutdown deadline")
+		}
+	}
+	if purgeDone != nil {
+		select {
+		case <-purgeDone:
+		case <-shutdownCtx.Done():
+			slog.Warn("token purge still running at shutdown deadline")
+		}
+	}
+
+	h.Close()
+	slog.Info("server stopped")
+	return runErr
+}
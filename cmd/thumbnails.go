@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"digital-community/internal/config"
+	"digital-community/internal/handlers"
+	"fmt"
+	"os/signal"
+	"syscall"
+)
+
+const thumbnailsUsage = `usage: server thumbnails rebuild [-missing]
+
+Regenerates every thumbnail under UPLOAD_ROOT/thumb. With -missing only
+absent or outdated thumbnails are generated.`
+
+func runThumbnails(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "rebuild" {
+		return usageError(thumbnailsUsage)
+	}
+	fs := newFlagSet("thumbnails rebuild", thumbnailsUsage)
+	missing := fs.Bool("missing", false, "only generate missing or outdated thumbnails")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError(thumbnailsUsage)
+	}
+
+	media := handlers.NewMedia(handlers.Deps{Config: cfg})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := media.RebuildThumbnails(ctx, !*missing)
+	fmt.Printf("generated %d, skipped %d, failed %d\n", stats.Generated, stats.Skipped, stats.Failed)
+	return err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"digital-community/internal/auth"
+	"digital-community/internal/config"
+	"digital-community/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const userUsage = `usage: server user <command>
+
+commands:
+  create-admin [-phone P] [-nick N] [-password-stdin] <username>
+                 create an admin account
+  reset-password [-password-stdin] <username>
+                 set a new password, sign the user out everywhere and clear
+                 any login lockout
+
+Without -password-stdin a random password is generated and printed once.`
+
+const minPasswordLen = 6
+
+var cliPhonePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+func runUser(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError(userUsage)
+	}
+	switch args[0] {
+	case "create-admin":
+		return runCreateAdmin(cfg, args[1:])
+	case "reset-password":
+		return runResetPassword(cfg, args[1:])
+	default:
+		return usageError(userUsage)
+	}
+}
+
+func runCreateAdmin(cfg *config.Config, args []string) error {
+	fs := newFlagSet("user create-admin", userUsage)
+	phone := fs.String("phone", "", "mobile number for SMS login")
+	nick := fs.String("nick", "", "display name (defaults to the username)")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(userUsage)
+	}
+	userName := strings.TrimSpace(fs.Arg(0))
+	if userName == "" {
+		return usageError(userUsage)
+	}
+	if *phone != "" && !cliPhonePattern.MatchString(*phone) {
+		return fmt.Errorf("invalid phone number %q", *phone)
+	}
+	if *nick == "" {
+		*nick = userName
+	}
+	password, generated, err := newPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("user_name = ?", userName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("user %q already exists; use `server user reset-password`", userName)
+	}
+	if *phone != "" {
+		if err := db.Model(&models.User{}).Where("phone = ?", *phone).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("phone %s is already registered", *phone)
+		}
+	}
+
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user := models.User{
+		UserName: userName,
+		NickName: *nick,
+		PassWord: hashed,
+		Phone:    *phone,
+		Sex:      "0",
+		Status:   models.UserStatusNormal,
+		DelFlag:  models.DelFlagExists,
+		Role:     models.RoleAdmin,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return err
+	}
+
+	fmt.Printf("created admin %s (id %d)\n", user.UserName, user.ID)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+	return nil
+}
+
+func runResetPassword(cfg *config.Config, args []string) error {
+	fs := newFlagSet("user reset-password", userUsage)
+	fromStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(userUsage)
+	}
+	userName := strings.TrimSpace(fs.Arg(0))
+	password, generated, err := newPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer config.CloseDB(db)
+
+	var user models.User
+	if err := db.Where("user_name = ?", userName).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %q not found", userName)
+		}
+		return err
+	}
+
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("pass_word", hashed).Error; err != nil {
+		return err
+	}
+	if err := auth.NewSessionStore(db, 0, nil).RevokeUser(int(user.ID)); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	if err := auth.NewLoginGuard(db, auth.GuardPolicy{}, nil).Unlock(int(user.ID)); err != nil {
+		return fmt.Errorf("clear lockout: %w", err)
+	}
+
+	fmt.Printf("password reset for %s (id %d)\n", user.UserName, user.ID)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+	if !user.IsActive() {
+		fmt.Println("note: the account is disabled or deleted and still cannot log in")
+	}
+	return nil
+}
+
+// newPassword reads the first line of stdin, or generates a random password
+// when fromStdin is false.
+func newPassword(fromStdin bool) (string, bool, error) {
+	if !fromStdin {
+		password, err := auth.RandomToken(8)
+		return password, true, err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < minPasswordLen {
+		return "", false, fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	return password, false, nil
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"digital-community/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GuardPolicy struct {
+	DelayAfter    int
+	MaxFailures   int
+	LockDuration  time.Duration
+	IPMaxFailures int
+	IPWindow      time.Duration
+}
+
+type ipFailures struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// LoginGuard tracks failed logins per account (persisted on the user row so
+// admins can see and clear it) and per client IP (in memory). After
+// DelayAfter failures every further attempt must wait an exponentially
+// growing delay, capped at LockDuration once MaxFailures is reached.
+type LoginGuard struct {
+	db     *gorm.DB
+	policy GuardPolicy
+	now    func() time.Time
+
+	mu  sync.Mutex
+	ips map[string]*ipFailures
+}
+
+// NewLoginGuard measures delays and locks with now; nil means time.Now.
+func NewLoginGuard(db *gorm.DB, policy GuardPolicy, now func() time.Time) *LoginGuard {
+	if now == nil {
+		now = time.Now
+	}
+	return &LoginGuard{db: db, policy: policy, now: now, ips: map[string]*ipFailures{}}
+}
+
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	if g.policy.MaxFailures > 0 && failures >= g.policy.MaxFailures {
+		return g.policy.LockDuration
+	}
+	if failures < g.policy.DelayAfter || g.policy.DelayAfter <= 0 {
+		return 0
+	}
+	d := time.Second << uint(failures-g.policy.DelayAfter)
+	if d > g.policy.LockDuration {
+		d = g.policy.LockDuration
+	}
+	return d
+}
+
+// Wait returns how long the caller must wait before another attempt is
+// accepted for this user and IP. user may be nil when the account is unknown.
+func (g *LoginGuard) Wait(user *models.User, ip string) time.Duration {
+	now := g.now()
+	var wait time.Duration
+	if user != nil && user.LockedUntil != nil && user.LockedUntil.After(now) {
+		wait = user.LockedUntil.Sub(now)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if rec, ok := g.ips[ip]; ok && rec.lockedUntil.After(now) {
+		if d := rec.lockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// RecordFailure increments the counter in SQL so concurrent failures are all
+// counted, then decides the lock from the count read back.
+func (g *LoginGuard) RecordFailure(user *models.User, ip string) error {
+	now := g.now()
+	g.recordIPFailure(ip, now)
+
+	if user == nil {
+		return nil
+	}
+	if err := g.db.Model(&models.User{}).Where("id = ?", user.ID).
+		UpdateColumn("failed_login_count", gorm.Expr("failed_login_count + ?", 1)).Error; err != nil {
+		return err
+	}
+	var failures int
+	if err := g.db.Model(&models.User{}).Where("id = ?", user.ID).Pluck("failed_login_count", &failures).Error; err != nil {
+		return err
+	}
+	user.FailedLoginCount = failures
+	d := g.backoff(failures)
+	if d <= 0 {
+		return nil
+	}
+	lockedUntil := now.Add(d)
+	user.LockedUntil = &lockedUntil
+	// Never shorten a lock set by a concurrent failure with a higher count.
+	return g.db.Model(&models.User{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", user.ID, lockedUntil).
+		UpdateColumn("locked_until", lockedUntil).Error
+}
+
+func (g *LoginGuard) recordIPFailure(ip string, now time.Time) {
+	if g.policy.IPMaxFailures <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec, ok := g.ips[ip]
+	if !ok || now.Sub(rec.first) > g.policy.IPWindow {
+		rec = &ipFailures{first: now}
+		g.ips[ip] = rec
+	}
+	rec.count++
+	if rec.count >= g.policy.IPMaxFailures {
+		rec.lockedUntil = now.Add(g.policy.LockDuration)
+	}
+
+	if len(g.ips) > 10000 {
+		for key, r := range g.ips {
+			if now.Sub(r.first) > g.policy.IPWindow && !r.lockedUntil.After(now) {
+				delete(g.ips, key)
+			}
+		}
+	}
+}
+
+// RecordSuccess clears only the account's counters. The IP record is left to
+// expire with IPWindow, otherwise logging into one's own account would reset
+// the count of a password spray against other accounts from the same IP.
+func (g *LoginGuard) RecordSuccess(user *models.User, ip string) error {
+	return g.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+		"login_date":         g.now().Format("2006-01-02 15:04:05"),
+		"ip":                 ip,
+	}).Error
+}
+
+func (g *LoginGuard) Unlock(userId int) error {
+	return g.db.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+	}).Error
+}
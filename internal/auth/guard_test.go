@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"digital-community/internal/auth"
+	"digital-community/internal/config"
+	"digital-community/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.CloseDB(db) })
+	return db
+}
+
+// Each attempt below works on the row as it was loaded before any failure was
+// written, the way parallel login requests do.
+func TestRecordFailureCountsParallelAttempts(t *testing.T) {
+	db := testDB(t)
+	user := models.User{UserName: "resident01", Phone: "13800000003"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	guard := auth.NewLoginGuard(db, auth.GuardPolicy{DelayAfter: 3, MaxFailures: 5, LockDuration: 15 * time.Minute}, nil)
+	for i := 0; i < 5; i++ {
+		stale := user
+		if err := guard.RecordFailure(&stale, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got models.User
+	if err := db.First(&got, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.FailedLoginCount != 5 {
+		t.Fatalf("failed_login_count = %d, want 5", got.FailedLoginCount)
+	}
+	if wait := guard.Wait(&got, "10.0.0.2"); wait < 14*time.Minute {
+		t.Fatalf("account should be locked for the full duration, wait %s", wait)
+	}
+}
+
+// A sprayer who also owns an account must not be able to reset the IP counter
+// by logging into it between guesses.
+func TestOwnLoginDoesNotResetIPFailures(t *testing.T) {
+	db := testDB(t)
+	own := models.User{UserName: "resident01", Phone: "13800000003"}
+	victim := models.User{UserName: "resident02", Phone: "13800000004"}
+	if err := db.Create(&own).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&victim).Error; err != nil {
+		t.Fatal(err)
+	}
+	guard := auth.NewLoginGuard(db, auth.GuardPolicy{MaxFailures: 100, LockDuration: 15 * time.Minute, IPMaxFailures: 4, IPWindow: 15 * time.Minute}, nil)
+
+	const ip = "10.0.0.9"
+	for i := 0; i < 4; i++ {
+		if err := guard.RecordFailure(&victim, ip); err != nil {
+			t.Fatal(err)
+		}
+		if i < 3 {
+			if err := guard.RecordSuccess(&own, ip); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if wait := guard.Wait(nil, ip); wait <= 0 {
+		t.Fatal("IP should be locked after IPMaxFailures failures despite successful logins in between")
+	}
+}
+
+func TestLockExpiresOnInjectedClock(t *testing.T) {
+	db := testDB(t)
+	user := models.User{UserName: "resident01", Phone: "13800000003"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	guard := auth.NewLoginGuard(db, auth.GuardPolicy{DelayAfter: 3, MaxFailures: 5, LockDuration: 15 * time.Minute}, func() time.Time { return now })
+	for i := 0; i < 5; i++ {
+		if err := guard.RecordFailure(&user, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait := guard.Wait(&user, "10.0.0.1"); wait != 15*time.Minute {
+		t.Fatalf("wait = %s, want the full lock", wait)
+	}
+	now = now.Add(16 * time.Minute)
+	if wait := guard.Wait(&user, "10.0.0.1"); wait != 0 {
+		t.Fatalf("lock should have expired, wait %s", wait)
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordCost = bcrypt.DefaultCost
+
+// dummyHash is compared against when the user does not exist so that lookups
+// for unknown accounts take as long as real password checks.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("digital-community"), passwordCost)
+
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword also accepts legacy plaintext rows, reporting them as needing
+// a rehash so callers can upgrade them after a successful login.
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if IsHashed(stored) {
+		if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(stored))
+		return true, err == nil && cost < passwordCost
+	}
+	if stored == "" {
+		return false, false
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1, true
+}
+
+func BurnPasswordCheck(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"digital-community/internal/models"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	ErrTokenVersionChanged = errors.New("token version changed")
+)
+
+type UserState struct {
+	TokenVersion int
+	Role         string
+	Active       bool
+}
+
+type cachedUserState struct {
+	state     UserState
+	fetchedAt time.Time
+}
+
+// SessionStore caches per-user state for stateTTL so the auth middleware does
+// not hit the database on every request. Changes made through this process are
+// visible immediately via InvalidateUser; other instances see them once their
+// cache entry expires.
+type SessionStore struct {
+	db       *gorm.DB
+	stateTTL time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	states map[int]cachedUserState
+}
+
+// NewSessionStore uses now for token expiry and revocation times; nil means
+// time.Now.
+func NewSessionStore(db *gorm.DB, stateTTL time.Duration, now func() time.Time) *SessionStore {
+	if now == nil {
+		now = time.Now
+	}
+	return &SessionStore{db: db, stateTTL: stateTTL, now: now, states: map[int]cachedUserState{}}
+}
+
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SessionStore) CreateRefreshToken(userId int, familyId string, ttl time.Duration) (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if familyId == "" {
+		familyId, err = RandomToken(16)
+		if err != nil {
+			return "", err
+		}
+	}
+	record := models.RefreshToken{
+		UserId:    userId,
+		TokenHash: hashToken(token),
+		FamilyId:  familyId,
+		ExpiresAt: s.now().Add(ttl),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken consumes a refresh token exactly once. Presenting a token
+// that was already used means it leaked, so the whole family is revoked.
+func (s *SessionStore) RotateRefreshToken(token string) (models.RefreshToken, error) {
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		return record, ErrRefreshTokenInvalid
+	}
+	now := s.now()
+	if record.RevokedAt != nil || now.After(record.ExpiresAt) {
+		return record, ErrRefreshTokenInvalid
+	}
+	if record.UsedAt != nil {
+		s.revokeRefreshFamily(record.FamilyId)
+		return record, ErrRefreshTokenInvalid
+	}
+
+	result := s.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return record, result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.revokeRefreshFamily(record.FamilyId)
+		return record, ErrRefreshTokenInvalid
+	}
+	return record, nil
+}
+
+func (s *SessionStore) RevokeRefreshToken(token string) error {
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.revokeRefreshFamily(record.FamilyId)
+}
+
+func (s *SessionStore) revokeRefreshFamily(familyId string) error {
+	return s.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", s.now()).Error
+}
+
+func (s *SessionStore) RevokeToken(jti string, userId int, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	record := models.RevokedToken{Jti: jti, UserId: userId, ExpiresAt: expiresAt}
+	return s.db.Where("jti = ?", jti).FirstOrCreate(&record).Error
+}
+
+func (s *SessionStore) IsRevoked(jti string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SessionStore) UserState(userId int) (UserState, error) {
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.states[userId]
+	s.mu.Unlock()
+	if ok && now.Sub(cached.fetchedAt) < s.stateTTL {
+		return cached.state, nil
+	}
+
+	var user models.User
+	var state UserState
+	err := s.db.Select("id", "token_version", "role", "status", "del_flag").First(&user, userId).Error
+	switch {
+	case err == nil:
+		state = UserState{TokenVersion: user.TokenVersion, Role: models.NormalizeRole(user.Role), Active: user.IsActive()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		state = UserState{Active: false}
+	default:
+		return state, err
+	}
+
+	s.mu.Lock()
+	if len(s.states) > 10000 {
+		s.states = map[int]cachedUserState{}
+	}
+	s.states[userId] = cachedUserState{state: state, fetchedAt: now}
+	s.mu.Unlock()
+	return state, nil
+}
+
+func (s *SessionStore) InvalidateUser(userId int) {
+	s.mu.Lock()
+	delete(s.states, userId)
+	s.mu.Unlock()
+}
+
+// RevokeUser invalidates every access and refresh token issued to the user so
+// far by bumping the version embedded in new tokens.
+func (s *SessionStore) RevokeUser(userId int) error {
+	defer s.InvalidateUser(userId)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userId).
+			UpdateColumn("token_version", gorm.Expr("token_version + ?", 1)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", s.now()).Error
+	})
+}
+
+// ResetPassword sets a new password hash and revokes every session in one
+// transaction, but only while the user's token version is still version.
+// ErrTokenVersionChanged means another reset or logout got there first.
+func (s *SessionStore) ResetPassword(userId, version int, passwordHash string) error {
+	defer s.InvalidateUser(userId)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ? AND token_version = ?", userId, version).
+			UpdateColumns(map[string]interface{}{
+				"pass_word":     passwordHash,
+				"token_version": gorm.Expr("token_version + ?", 1),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenVersionChanged
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", s.now()).Error
+	})
+}
+
+// StartPurger deletes expired revocations and refresh tokens every interval
+// until ctx is cancelled. The returned channel closes once it has stopped.
+func (s *SessionStore) StartPurger(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.PurgeExpired(); err != nil {
+				slog.Error("purging expired tokens failed", "error", err)
+			}
+		}
+	}()
+	return done
+}
+
+func (s *SessionStore) PurgeExpired() error {
+	now := s.now()
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.Unscoped().Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
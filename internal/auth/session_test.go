@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"context"
+	"digital-community/internal/auth"
+	"digital-community/internal/config"
+	"digital-community/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgerRemovesExpiredTokens(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.CloseDB(db) })
+
+	now := time.Now()
+	tokens := []models.RevokedToken{
+		{Jti: "expired", UserId: 3, ExpiresAt: now.Add(-time.Minute)},
+		{Jti: "live", UserId: 3, ExpiresAt: now.Add(time.Hour)},
+	}
+	if err := db.Create(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := auth.NewSessionStore(db, 0, nil).StartPurger(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var left []string
+		if err := db.Model(&models.RevokedToken{}).Pluck("jti", &left).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(left) == 1 && left[0] == "live" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired token not purged, left %v", left)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("purger did not stop after cancel")
+	}
+}
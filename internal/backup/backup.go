@@ -0,0 +1,199 @@
+// Package backup archives the SQLite database together with the upload
+// directory into a single tar.gz.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"digital-community/internal/buildinfo"
+	"digital-community/internal/migrations"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	manifestEntry = "manifest.json"
+	dbEntry       = "data.db"
+	uploadPrefix  = "upload/"
+	namePrefix    = "backup-"
+	nameSuffix    = ".tar.gz"
+)
+
+// mu serialises Create so scheduled and on-demand backups in one process
+// never snapshot at the same time.
+var mu sync.Mutex
+
+type Manifest struct {
+	CreatedAt     time.Time `json:"createdAt"`
+	AppVersion    string    `json:"appVersion"`
+	SchemaVersion int       `json:"schemaVersion"`
+	UploadFiles   int       `json:"uploadFiles"`
+}
+
+// Create writes dir/backup-<timestamp>.tar.gz and returns its path. The
+// database copy comes from VACUUM INTO, which is consistent while the server
+// keeps writing. Generated thumbnails are left out; they are rebuilt on
+// start.
+func Create(ctx context.Context, db *gorm.DB, uploadRoot, dir string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name := db.Dialector.Name(); name != "sqlite" {
+		return "", fmt.Errorf("backup only supports sqlite, not %s; use the database's own dump tool", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	snapshot, err := os.CreateTemp(dir, ".snapshot-*.db")
+	if err != nil {
+		return "", err
+	}
+	snapshotPath := snapshot.Name()
+	snapshot.Close()
+	// VACUUM INTO refuses to overwrite an existing file.
+	os.Remove(snapshotPath)
+	defer os.Remove(snapshotPath)
+	if err := db.WithContext(ctx).Exec("VACUUM INTO ?", snapshotPath).Error; err != nil {
+		return "", fmt.Errorf("snapshot database: %w", err)
+	}
+
+	schemaVersion, err := migrations.Current(db)
+	if err != nil {
+		return "", err
+	}
+	manifest := Manifest{
+		CreatedAt:     time.Now(),
+		AppVersion:    buildinfo.Version,
+		SchemaVersion: schemaVersion,
+	}
+
+	name := uniqueName(dir, manifest.CreatedAt)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := writeArchive(ctx, tmp, snapshotPath, uploadRoot, &manifest); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func uniqueName(dir string, t time.Time) string {
+	base := namePrefix + t.Format("20060102-150405")
+	name := base + nameSuffix
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d%s", base, i, nameSuffix)
+	}
+}
+
+func writeArchive(ctx context.Context, w io.Writer, snapshotPath, uploadRoot string, manifest *Manifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := addFile(tw, snapshotPath, dbEntry); err != nil {
+		return err
+	}
+
+	thumbDir := filepath.Join(uploadRoot, "thumb")
+	err := filepath.Walk(uploadRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == uploadRoot {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() {
+			// Hidden directories hold restore staging, not uploads.
+			if path == thumbDir || (path != uploadRoot && strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(uploadRoot, path)
+		if err != nil {
+			return err
+		}
+		manifest.UploadFiles++
+		return addFile(tw, path, uploadPrefix+filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return fmt.Errorf("archive uploads: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestEntry,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,225 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"digital-community/internal/backup"
+	"digital-community/internal/config"
+	"digital-community/internal/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type site struct {
+	dir, dbPath, uploadRoot, backupDir string
+}
+
+func newSite(t *testing.T) site {
+	t.Helper()
+	dir := t.TempDir()
+	s := site{
+		dir:        dir,
+		dbPath:     filepath.Join(dir, "data", "data.db"),
+		uploadRoot: filepath.Join(dir, "upload"),
+		backupDir:  filepath.Join(dir, "backups"),
+	}
+	for _, d := range []string{filepath.Dir(s.dbPath), s.uploadRoot} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noStaging fails when Stage left files behind after an error.
+func (s site) noStaging(t *testing.T) {
+	t.Helper()
+	for _, pattern := range []string{filepath.Join(filepath.Dir(s.dbPath), ".restore-*"), filepath.Join(s.uploadRoot, ".restore-*")} {
+		if left, _ := filepath.Glob(pattern); len(left) > 0 {
+			t.Fatalf("staging files left behind: %v", left)
+		}
+	}
+}
+
+func TestCreateStageCommitRoundTrip(t *testing.T) {
+	s := newSite(t)
+	cfg := config.Defaults()
+	cfg.DBPath = s.dbPath
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.User{UserName: "kept", Phone: "13800000003"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(s.uploadRoot, "2024", "05", "kept.txt"), "kept")
+	writeFile(t, filepath.Join(s.uploadRoot, "thumb", "kept.jpg"), "thumbnail")
+
+	archive, err := backup.Create(context.Background(), db, s.uploadRoot, s.backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change everything after the backup was taken.
+	if err := db.Where("user_name = ?", "kept").Delete(&models.User{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.User{UserName: "later", Phone: "13800000004"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(s.uploadRoot, "2024")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(s.uploadRoot, "later.txt"), "later")
+	config.CloseDB(db)
+
+	staged, err := backup.Stage(archive, s.dbPath, s.uploadRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staged.Manifest.UploadFiles != 1 {
+		t.Fatalf("manifest lists %d uploads, want 1 (thumbnails are left out)", staged.Manifest.UploadFiles)
+	}
+	if _, err := os.Stat(filepath.Join(s.uploadRoot, "later.txt")); err != nil {
+		t.Fatal("Stage must not touch live uploads")
+	}
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := os.ReadFile(filepath.Join(s.uploadRoot, "2024", "05", "kept.txt")); err != nil || string(got) != "kept" {
+		t.Fatalf("restored upload: %q, %v", got, err)
+	}
+	for _, gone := range []string{"later.txt", "thumb"} {
+		if _, err := os.Stat(filepath.Join(s.uploadRoot, gone)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be gone after restore", gone)
+		}
+	}
+	s.noStaging(t)
+
+	restored, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer config.CloseDB(restored)
+	var names []string
+	if err := restored.Model(&models.User{}).Pluck("user_name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "kept" {
+		t.Fatalf("restored users %v, want [kept]", names)
+	}
+}
+
+type entry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+func writeArchive(t *testing.T, path string, entries []entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: typeflag, Mode: 0644, Linkname: e.linkname}
+		if typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func manifest(t *testing.T, uploads int) entry {
+	t.Helper()
+	data, err := json.Marshal(backup.Manifest{UploadFiles: uploads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry{name: "manifest.json", body: string(data)}
+}
+
+func TestStageRejectsMaliciousArchives(t *testing.T) {
+	db := entry{name: "data.db", body: "not checked before the entries are"}
+	cases := []struct {
+		name    string
+		entries []entry
+		want    string
+	}{
+		{"parent directory", []entry{manifest(t, 0), db, {name: "../evil", body: "x"}}, "escapes"},
+		{"absolute path", []entry{manifest(t, 0), db, {name: "/tmp/evil", body: "x"}}, "escapes"},
+		{"upload climbing out", []entry{manifest(t, 1), db, {name: "upload/../../evil", body: "x"}}, "escapes"},
+		{"symlink", []entry{manifest(t, 1), db, {name: "upload/link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}}, "not a regular file"},
+		{"hard link", []entry{manifest(t, 1), db, {name: "upload/link", typeflag: tar.TypeLink, linkname: "data.db"}}, "not a regular file"},
+		{"unknown entry", []entry{manifest(t, 0), db, {name: "cmd.sh", body: "x"}}, "unexpected archive entry"},
+		{"missing database", []entry{manifest(t, 0)}, "missing"},
+		{"upload count mismatch", []entry{manifest(t, 2), db, {name: "upload/a.txt", body: "a"}}, "manifest lists 2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSite(t)
+			writeFile(t, s.dbPath, "live database")
+			writeFile(t, filepath.Join(s.uploadRoot, "live.txt"), "live")
+			archive := filepath.Join(s.dir, "crafted.tar.gz")
+			writeArchive(t, archive, tc.entries)
+
+			staged, err := backup.Stage(archive, s.dbPath, s.uploadRoot)
+			if err == nil {
+				staged.Discard()
+				t.Fatal("Stage accepted a malicious archive")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error %q, want it to mention %q", err, tc.want)
+			}
+			s.noStaging(t)
+			if got, _ := os.ReadFile(s.dbPath); string(got) != "live database" {
+				t.Fatal("live database changed")
+			}
+			if _, err := os.Stat(filepath.Join(s.uploadRoot, "live.txt")); err != nil {
+				t.Fatal("live uploads changed")
+			}
+			for _, escaped := range []string{filepath.Join(s.dir, "evil"), filepath.Join(filepath.Dir(s.dir), "evil")} {
+				if _, err := os.Stat(escaped); !os.IsNotExist(err) {
+					t.Fatalf("entry written outside the target: %s", escaped)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"digital-community/internal/migrations"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Staged is an archive extracted next to the live data and fully validated.
+// Nothing live changes until Commit.
+type Staged struct {
+	Manifest Manifest
+
+	dbPath     string
+	uploadRoot string
+	stageDB    string
+	stageDir   string
+}
+
+// Stage extracts archivePath beside dbPath and inside uploadRoot (so the
+// final swap is a rename on the same filesystem, also when both are volume
+// mounts) and checks the manifest, every entry name, the database integrity
+// and its schema version.
+func Stage(archivePath, dbPath, uploadRoot string) (*Staged, error) {
+	if err := os.MkdirAll(uploadRoot, 0755); err != nil {
+		return nil, err
+	}
+	dbFile, err := os.CreateTemp(filepath.Dir(dbPath), ".restore-*.db")
+	if err != nil {
+		return nil, err
+	}
+	dbFile.Close()
+	stageDir, err := os.MkdirTemp(uploadRoot, ".restore-")
+	if err != nil {
+		os.Remove(dbFile.Name())
+		return nil, err
+	}
+	s := &Staged{dbPath: dbPath, uploadRoot: uploadRoot, stageDB: dbFile.Name(), stageDir: stageDir}
+
+	if err := s.extract(archivePath); err != nil {
+		s.Discard()
+		return nil, err
+	}
+	if err := s.validateDB(); err != nil {
+		s.Discard()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Staged) extract(archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	var haveManifest, haveDB bool
+	uploads := 0
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive entry %q escapes the target directory", hdr.Name)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("archive entry %q is not a regular file", hdr.Name)
+		}
+
+		switch {
+		case name == manifestEntry:
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(&s.Manifest); err != nil {
+				return fmt.Errorf("invalid manifest: %w", err)
+			}
+			haveManifest = true
+		case name == dbEntry:
+			if err := writeEntry(s.stageDB, tr, 0644); err != nil {
+				return err
+			}
+			haveDB = true
+		case strings.HasPrefix(name, uploadPrefix):
+			target := filepath.Join(s.stageDir, filepath.FromSlash(strings.TrimPrefix(name, uploadPrefix)))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeEntry(target, tr, os.FileMode(hdr.Mode).Perm()|0600); err != nil {
+				return err
+			}
+			uploads++
+		default:
+			return fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+	}
+
+	if !haveManifest || !haveDB {
+		return errors.New("archive is missing manifest.json or data.db")
+	}
+	if uploads != s.Manifest.UploadFiles {
+		return fmt.Errorf("archive has %d upload files, manifest lists %d", uploads, s.Manifest.UploadFiles)
+	}
+	return nil
+}
+
+func writeEntry(target string, r io.Reader, perm os.FileMode) error {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *Staged) validateDB() error {
+	if s.Manifest.SchemaVersion > migrations.Latest() {
+		return fmt.Errorf("backup schema version %d is newer than this build supports (%d)", s.Manifest.SchemaVersion, migrations.Latest())
+	}
+	db, err := gorm.Open(sqlite.Open(s.stageDB), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("check database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("database integrity check failed: %s", result)
+	}
+	version, err := migrations.Current(db)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if version != s.Manifest.SchemaVersion {
+		return fmt.Errorf("database schema version %d does not match manifest (%d)", version, s.Manifest.SchemaVersion)
+	}
+	return nil
+}
+
+// Commit swaps the staged database and uploads in. The server must not be
+// running. Thumbnails are not restored; the server rebuilds them on start.
+func (s *Staged) Commit() error {
+	// A leftover journal from the old database must not be replayed onto the
+	// restored one.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(s.dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(s.stageDB, s.dbPath); err != nil {
+		return fmt.Errorf("swap database: %w", err)
+	}
+
+	// uploadRoot itself may be a mount point, so swap its contents rather
+	// than the directory.
+	entries, err := os.ReadDir(s.uploadRoot)
+	if err != nil {
+		return err
+	}
+	stageName := filepath.Base(s.stageDir)
+	for _, entry := range entries {
+		if entry.Name() == stageName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.uploadRoot, entry.Name())); err != nil {
+			return fmt.Errorf("clear uploads: %w", err)
+		}
+	}
+	staged, err := os.ReadDir(s.stageDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range staged {
+		if err := os.Rename(filepath.Join(s.stageDir, entry.Name()), filepath.Join(s.uploadRoot, entry.Name())); err != nil {
+			return fmt.Errorf("swap uploads: %w", err)
+		}
+	}
+	return os.Remove(s.stageDir)
+}
+
+// Discard removes the staged files.
+func (s *Staged) Discard() {
+	os.Remove(s.stageDB)
+	os.RemoveAll(s.stageDir)
+}
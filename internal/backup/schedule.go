@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var namePattern = regexp.MustCompile(`^backup-\d{8}-\d{6}(-\d+)?\.tar\.gz$`)
+
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// List returns the archives in dir, newest first. A missing dir is empty.
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !namePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Info{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	// Names embed the timestamp, so they sort chronologically.
+	sort.Slice(list, func(i, j int) bool { return list[i].Name > list[j].Name })
+	return list, nil
+}
+
+// Path resolves an archive name from List, rejecting anything else so the
+// name can come from a request.
+func Path(dir, name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Prune deletes all but the newest keep archives in dir.
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	list, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, info := range list[min(keep, len(list)):] {
+		if err := os.Remove(filepath.Join(dir, info.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, info.Name)
+	}
+	return removed, nil
+}
+
+// StartScheduler creates a backup every interval and prunes dir to keep
+// archives. The returned channel is closed once ctx is cancelled and any
+// backup in progress has finished.
+func StartScheduler(ctx context.Context, db *gorm.DB, uploadRoot, dir string, interval time.Duration, keep int) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			path, err := Create(ctx, db, uploadRoot, dir)
+			if err != nil {
+				slog.Error("scheduled backup failed", "error", err)
+				continue
+			}
+			slog.Info("scheduled backup created", "path", path)
+			removed, err := Prune(dir, keep)
+			if err != nil {
+				slog.Error("backup retention failed", "error", err)
+			}
+			for _, name := range removed {
+				slog.Info("old backup removed", "name", name)
+			}
+		}
+	}()
+	return done
+}
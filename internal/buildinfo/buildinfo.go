@@ -0,0 +1,33 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X digital-community/internal/buildinfo.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+var StartTime = time.Now()
+
+// CommitHash falls back to the VCS revision recorded by the Go toolchain when
+// Commit was not injected through ldflags.
+func CommitHash() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
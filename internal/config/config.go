@@ -1,26 +1,120 @@
 package config
 
 import (
-	"os"
+	"time"
 )
 
+// Config fields are filled from Defaults, then the YAML file, then the env
+// var named in the env tag, then the command-line flag. The YAML key is the
+// env var in lower case and the flag is the YAML key with dashes.
 type Config struct {
-	ServerPort string
-	JWTSecret  string
-	DBPath     string
+	ServerPort       string        `yaml:"server_port" env:"SERVER_PORT"`
+	JWTSecret        string        `yaml:"jwt_secret" env:"JWT_SECRET"`
+	DevMode          bool          `yaml:"dev_mode" env:"DEV_MODE"`
+	DBDriver         string        `yaml:"db_driver" env:"DB_DRIVER"`
+	DBDSN            string        `yaml:"db_dsn" env:"DB_DSN"`
+	DBPath           string        `yaml:"db_path" env:"DB_PATH"`
+	DBAutoMigrate    bool          `yaml:"db_auto_migrate" env:"DB_AUTO_MIGRATE"`
+	AutoSeed         bool          `yaml:"auto_seed" env:"AUTO_SEED"`
+	AccessTokenTTL   time.Duration `yaml:"jwt_access_ttl" env:"JWT_ACCESS_TTL"`
+	RefreshTokenTTL  time.Duration `yaml:"jwt_refresh_ttl" env:"JWT_REFRESH_TTL"`
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL"`
+
+	SMSProvider      string        `yaml:"sms_provider" env:"SMS_PROVIDER"`
+	SMSLogFile       string        `yaml:"sms_log_file" env:"SMS_LOG_FILE"`
+	SMSGatewayURL    string        `yaml:"sms_gateway_url" env:"SMS_GATEWAY_URL"`
+	SMSGatewayKey    string        `yaml:"sms_gateway_key" env:"SMS_GATEWAY_KEY"`
+	SMSTemplate      string        `yaml:"sms_template" env:"SMS_TEMPLATE"`
+	SMSDevMode       bool          `yaml:"sms_dev_mode" env:"SMS_DEV_MODE"`
+	SMSCodeTTL       time.Duration `yaml:"sms_code_ttl" env:"SMS_CODE_TTL"`
+	SMSPhoneInterval time.Duration `yaml:"sms_phone_interval" env:"SMS_PHONE_INTERVAL"`
+	SMSIPLimit       int           `yaml:"sms_ip_limit" env:"SMS_IP_LIMIT"`
+	SMSIPWindow      time.Duration `yaml:"sms_ip_window" env:"SMS_IP_WINDOW"`
+	SMSMaxAttempts   int           `yaml:"sms_max_attempts" env:"SMS_MAX_ATTEMPTS"`
+	CodeStore        string        `yaml:"code_store" env:"CODE_STORE"`
+
+	LoginDelayAfter    int           `yaml:"login_delay_after" env:"LOGIN_DELAY_AFTER"`
+	LoginMaxFailures   int           `yaml:"login_max_failures" env:"LOGIN_MAX_FAILURES"`
+	LoginLockDuration  time.Duration `yaml:"login_lock_duration" env:"LOGIN_LOCK_DURATION"`
+	LoginIPMaxFailures int           `yaml:"login_ip_max_failures" env:"LOGIN_IP_MAX_FAILURES"`
+	LoginIPWindow      time.Duration `yaml:"login_ip_window" env:"LOGIN_IP_WINDOW"`
+
+	UserStateCacheTTL    time.Duration `yaml:"user_state_cache_ttl" env:"USER_STATE_CACHE_TTL"`
+	SessionPurgeInterval time.Duration `yaml:"session_purge_interval" env:"SESSION_PURGE_INTERVAL"`
+
+	StrictHTTPStatus bool          `yaml:"http_strict_status" env:"HTTP_STRICT_STATUS"`
+	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+
+	APIPrefix          string   `yaml:"api_prefix" env:"API_PREFIX"`
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	TrustedProxies     []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	UploadRoot        string `yaml:"upload_root" env:"UPLOAD_ROOT"`
+	ThumbnailMaxSide  int    `yaml:"thumbnail_max_side" env:"THUMBNAIL_MAX_SIDE"`
+	ThumbnailMaxBytes int    `yaml:"thumbnail_max_bytes" env:"THUMBNAIL_MAX_BYTES"`
+
+	DefaultPageSize  int `yaml:"page_size_default" env:"PAGE_SIZE_DEFAULT"`
+	MaxPageSize      int `yaml:"page_size_max" env:"PAGE_SIZE_MAX"`
+	MediaMaxPageSize int `yaml:"media_page_size_max" env:"MEDIA_PAGE_SIZE_MAX"`
+
+	BackupDir      string        `yaml:"backup_dir" env:"BACKUP_DIR"`
+	BackupInterval time.Duration `yaml:"backup_interval" env:"BACKUP_INTERVAL"`
+	BackupKeep     int           `yaml:"backup_keep" env:"BACKUP_KEEP"`
+
+	LogLevel        string        `yaml:"log_level" env:"LOG_LEVEL"`
+	DBSlowThreshold time.Duration `yaml:"db_slow_threshold" env:"DB_SLOW_THRESHOLD"`
+	// MetricsToken enables /metrics for scrapers that send it as a bearer
+	// token; the endpoint is not served while it is empty.
+	MetricsToken string `yaml:"metrics_token" env:"METRICS_TOKEN"`
 }
 
-func Load() *Config {
+func Defaults() *Config {
 	return &Config{
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		JWTSecret:  getEnv("JWT_SECRET", "digital-community-secret-key-2024"),
-		DBPath:     getEnv("DB_PATH", "./data.db"),
-	}
-}
+		ServerPort:       "8080",
+		DBDriver:         "sqlite",
+		DBPath:           "./data.db",
+		DBAutoMigrate:    true,
+		AutoSeed:         false,
+		AccessTokenTTL:   2 * time.Hour,
+		RefreshTokenTTL:  30 * 24 * time.Hour,
+		PasswordResetTTL: 10 * time.Minute,
+
+		SMSProvider:      "log",
+		SMSTemplate:      "verify_code",
+		SMSCodeTTL:       5 * time.Minute,
+		SMSPhoneInterval: time.Minute,
+		SMSIPLimit:       10,
+		SMSIPWindow:      time.Hour,
+		SMSMaxAttempts:   5,
+		CodeStore:        "memory",
+
+		LoginDelayAfter:    3,
+		LoginMaxFailures:   5,
+		LoginLockDuration:  15 * time.Minute,
+		LoginIPMaxFailures: 20,
+		LoginIPWindow:      15 * time.Minute,
+
+		UserStateCacheTTL:    30 * time.Second,
+		SessionPurgeInterval: time.Hour,
+
+		ShutdownTimeout: 15 * time.Second,
+
+		APIPrefix:          "/prod-api/api",
+		CORSAllowedOrigins: []string{"*"},
+		TrustedProxies:     []string{"127.0.0.1", "::1"},
+
+		UploadRoot:        "./profile/upload",
+		ThumbnailMaxSide:  140,
+		ThumbnailMaxBytes: 28 * 1024,
+
+		DefaultPageSize:  10,
+		MaxPageSize:      100,
+		MediaMaxPageSize: 60,
+
+		BackupDir:  "./backups",
+		BackupKeep: 7,
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+		LogLevel:        "info",
+		DBSlowThreshold: 200 * time.Millisecond,
 	}
-	return defaultValue
 }
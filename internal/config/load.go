@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the YAML file when -config is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load builds the configuration from defaults, the YAML file, environment
+// variables and flags in args (each overriding the previous), validates it
+// and returns it with the arguments left after the flags.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Defaults()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "flags (each can also be set by the env var shown or in the -config file):")
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", os.Getenv(ConfigFileEnv), "YAML config file (env "+ConfigFileEnv+")")
+	flagValues := map[string]string{}
+	forEachField(cfg, func(field reflect.StructField, _ reflect.Value) {
+		name := strings.ReplaceAll(field.Tag.Get("yaml"), "_", "-")
+		usage := "env " + field.Tag.Get("env")
+		if field.Type.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, func(raw string) error {
+				flagValues[field.Name] = raw
+				return nil
+			})
+			return
+		}
+		fs.Func(name, usage, func(raw string) error {
+			flagValues[field.Name] = raw
+			return nil
+		})
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	forEachField(cfg, func(field reflect.StructField, v reflect.Value) {
+		env := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(env)
+		// An empty variable counts as unset, as docker-compose passes
+		// "${VAR:-}" through that way.
+		if !ok || raw == "" {
+			return
+		}
+		if err := setField(v, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", env, err))
+		}
+	})
+	forEachField(cfg, func(field reflect.StructField, v reflect.Value) {
+		raw, ok := flagValues[field.Name]
+		if !ok {
+			return
+		}
+		if err := setField(v, raw); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", strings.ReplaceAll(field.Tag.Get("yaml"), "_", "-"), err))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if cfg.DevMode && cfg.JWTSecret == "" {
+		// Tokens stop working on restart, which is fine for development.
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		cfg.JWTSecret = hex.EncodeToString(secret)
+	}
+	return cfg, fs.Args(), nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// An empty or comment-only file decodes as io.EOF.
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func forEachField(cfg *Config, fn func(field reflect.StructField, v reflect.Value)) {
+	rv := reflect.ValueOf(cfg).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		fn(rt.Field(i), rv.Field(i))
+	}
+}
+
+func setField(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (want e.g. 30s, 15m, 2h)", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package config_test
+
+import (
+	"digital-community/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv blanks every variable the table touches so the host environment
+// cannot leak into a case; Load treats an empty variable as unset.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{config.ConfigFileEnv, "JWT_SECRET", "DEV_MODE", "SERVER_PORT", "LOG_LEVEL", "SMS_CODE_TTL", "SMS_IP_LIMIT", "CORS_ALLOWED_ORIGINS"} {
+		t.Setenv(key, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yaml := writeConfig(t, "jwt_secret: from-yaml\nserver_port: \"7000\"\nlog_level: warn\nsms_code_ttl: 2m\n")
+	cases := []struct {
+		name  string
+		env   map[string]string
+		flags []string
+		check func(*config.Config) string
+	}{
+		{
+			name: "yaml over defaults",
+			check: func(cfg *config.Config) string {
+				if cfg.ServerPort != "7000" || cfg.SMSCodeTTL != 2*time.Minute || cfg.DBDriver != "sqlite" {
+					return "yaml values not applied on top of defaults"
+				}
+				return ""
+			},
+		},
+		{
+			name: "env over yaml",
+			env:  map[string]string{"SERVER_PORT": "7001", "CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example"},
+			check: func(cfg *config.Config) string {
+				if cfg.ServerPort != "7001" || cfg.LogLevel != "warn" {
+					return "env should override only the keys it sets"
+				}
+				if strings.Join(cfg.CORSAllowedOrigins, " ") != "https://a.example https://b.example" {
+					return "comma-separated env list not split"
+				}
+				return ""
+			},
+		},
+		{
+			name:  "flags over env",
+			env:   map[string]string{"SERVER_PORT": "7001", "LOG_LEVEL": "error"},
+			flags: []string{"-server-port", "7002", "-sms-code-ttl", "90s"},
+			check: func(cfg *config.Config) string {
+				if cfg.ServerPort != "7002" || cfg.SMSCodeTTL != 90*time.Second || cfg.LogLevel != "error" {
+					return "flags should override only the keys they set"
+				}
+				return ""
+			},
+		},
+		{
+			name: "empty env counts as unset",
+			env:  map[string]string{"JWT_SECRET": ""},
+			check: func(cfg *config.Config) string {
+				if cfg.JWTSecret != "from-yaml" {
+					return "empty env var replaced the yaml value"
+				}
+				return ""
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg, rest, err := config.Load(append([]string{"-config", yaml}, append(tc.flags, "extra")...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rest) != 1 || rest[0] != "extra" {
+				t.Fatalf("remaining args %v, want [extra]", rest)
+			}
+			if msg := tc.check(cfg); msg != "" {
+				t.Fatalf("%s: %+v", msg, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	cases := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		flags []string
+		want  string
+	}{
+		{name: "unknown yaml key", yaml: "jwt_secret: s\nserver_prot: \"7000\"\n", want: "field server_prot not found"},
+		{name: "bad env duration", yaml: "jwt_secret: s\n", env: map[string]string{"SMS_CODE_TTL": "5"}, want: "SMS_CODE_TTL: invalid duration"},
+		{name: "bad flag integer", yaml: "jwt_secret: s\n", flags: []string{"-sms-ip-limit", "many"}, want: "-sms-ip-limit: invalid integer"},
+		{name: "validation runs last", yaml: "jwt_secret: s\n", flags: []string{"-server-port", "0"}, want: "SERVER_PORT: must be a port number"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			_, _, err := config.Load(append([]string{"-config", writeConfig(t, tc.yaml)}, tc.flags...))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadDevModeGeneratesSecret(t *testing.T) {
+	clearEnv(t)
+	cfg, _, err := config.Load([]string{"-dev-mode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.JWTSecret) != 64 {
+		t.Fatalf("dev mode secret %q, want 32 random bytes in hex", cfg.JWTSecret)
+	}
+}
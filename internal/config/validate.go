@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// publicJWTSecrets appeared in earlier releases and the example config, so
+// tokens signed with them can be forged by anyone.
+var publicJWTSecrets = map[string]bool{
+	"digital-community-secret-key-2024": true,
+	"change-me":                         true,
+}
+
+// Validate reports every invalid setting at once, named by its env var.
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			fail(key, "must be greater than 0, got %s", d)
+		}
+	}
+	atLeast := func(key string, n, min int) {
+		if n < min {
+			fail(key, "must be at least %d, got %d", min, n)
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		fail(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	if port, err := strconv.Atoi(cfg.ServerPort); err != nil || port < 1 || port > 65535 {
+		fail("SERVER_PORT", "must be a port number between 1 and 65535, got %q", cfg.ServerPort)
+	}
+	if !cfg.DevMode {
+		if cfg.JWTSecret == "" {
+			fail("JWT_SECRET", "must be set (DEV_MODE=true generates a throwaway one for local development)")
+		} else if publicJWTSecrets[cfg.JWTSecret] {
+			fail("JWT_SECRET", "is a published example value; set a private random secret")
+		}
+	}
+	positive("JWT_ACCESS_TTL", cfg.AccessTokenTTL)
+	positive("JWT_REFRESH_TTL", cfg.RefreshTokenTTL)
+	positive("PASSWORD_RESET_TTL", cfg.PasswordResetTTL)
+
+	oneOf("DB_DRIVER", cfg.DBDriver, "sqlite", "sqlite3", "mysql", "postgres", "postgresql")
+	switch cfg.DBDriver {
+	case "mysql", "postgres", "postgresql":
+		if cfg.DBDSN == "" {
+			fail("DB_DSN", "is required when DB_DRIVER is %s", cfg.DBDriver)
+		}
+	default:
+		if cfg.DBDSN == "" && cfg.DBPath == "" {
+			fail("DB_PATH", "must not be empty")
+		}
+	}
+	if cfg.DBSlowThreshold < 0 {
+		fail("DB_SLOW_THRESHOLD", "must not be negative")
+	}
+
+	oneOf("SMS_PROVIDER", cfg.SMSProvider, "log", "http")
+	if cfg.SMSProvider == "http" {
+		if u, err := url.Parse(cfg.SMSGatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("SMS_GATEWAY_URL", "must be an http(s) URL when SMS_PROVIDER is http, got %q", cfg.SMSGatewayURL)
+		}
+	}
+	positive("SMS_CODE_TTL", cfg.SMSCodeTTL)
+	positive("SMS_PHONE_INTERVAL", cfg.SMSPhoneInterval)
+	atLeast("SMS_IP_LIMIT", cfg.SMSIPLimit, 1)
+	positive("SMS_IP_WINDOW", cfg.SMSIPWindow)
+	atLeast("SMS_MAX_ATTEMPTS", cfg.SMSMaxAttempts, 1)
+	oneOf("CODE_STORE", cfg.CodeStore, "memory", "sqlite", "database")
+
+	atLeast("LOGIN_DELAY_AFTER", cfg.LoginDelayAfter, 0)
+	atLeast("LOGIN_MAX_FAILURES", cfg.LoginMaxFailures, 1)
+	positive("LOGIN_LOCK_DURATION", cfg.LoginLockDuration)
+	atLeast("LOGIN_IP_MAX_FAILURES", cfg.LoginIPMaxFailures, 1)
+	positive("LOGIN_IP_WINDOW", cfg.LoginIPWindow)
+	if cfg.UserStateCacheTTL < 0 {
+		fail("USER_STATE_CACHE_TTL", "must not be negative")
+	}
+	positive("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+
+	if !strings.HasPrefix(cfg.APIPrefix, "/") || strings.HasSuffix(cfg.APIPrefix, "/") || strings.HasPrefix(cfg.APIPrefix, "/profile") {
+		fail("API_PREFIX", "must start with / and not end with / or overlap /profile, got %q", cfg.APIPrefix)
+	}
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		fail("CORS_ALLOWED_ORIGINS", "must list at least one origin (or *)")
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			fail("CORS_ALLOWED_ORIGINS", "%q is not an origin like https://example.com", origin)
+		}
+	}
+
+	if cfg.UploadRoot == "" {
+		fail("UPLOAD_ROOT", "must not be empty")
+	}
+	if cfg.ThumbnailMaxSide < 16 || cfg.ThumbnailMaxSide > 2048 {
+		fail("THUMBNAIL_MAX_SIDE", "must be between 16 and 2048, got %d", cfg.ThumbnailMaxSide)
+	}
+	atLeast("THUMBNAIL_MAX_BYTES", cfg.ThumbnailMaxBytes, 1024)
+
+	atLeast("PAGE_SIZE_MAX", cfg.MaxPageSize, 1)
+	if cfg.DefaultPageSize < 1 || cfg.DefaultPageSize > cfg.MaxPageSize {
+		fail("PAGE_SIZE_DEFAULT", "must be between 1 and PAGE_SIZE_MAX (%d), got %d", cfg.MaxPageSize, cfg.DefaultPageSize)
+	}
+	atLeast("MEDIA_PAGE_SIZE_MAX", cfg.MediaMaxPageSize, 1)
+
+	if cfg.SessionPurgeInterval < 0 {
+		fail("SESSION_PURGE_INTERVAL", "must not be negative")
+	}
+	if cfg.BackupInterval < 0 {
+		fail("BACKUP_INTERVAL", "must not be negative")
+	}
+	if cfg.BackupInterval > 0 && cfg.BackupDir == "" {
+		fail("BACKUP_DIR", "is required when BACKUP_INTERVAL is set")
+	}
+	atLeast("BACKUP_KEEP", cfg.BackupKeep, 0)
+
+	oneOf("LOG_LEVEL", strings.ToLower(cfg.LogLevel), "debug", "info", "warn", "error")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config_test
+
+import (
+	"digital-community/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*config.Config)
+		want   []string
+	}{
+		{name: "valid", modify: func(*config.Config) {}},
+		{name: "missing secret", modify: func(c *config.Config) { c.JWTSecret = "" }, want: []string{"JWT_SECRET: must be set"}},
+		{name: "published secret", modify: func(c *config.Config) { c.JWTSecret = "digital-community-secret-key-2024" }, want: []string{"JWT_SECRET: is a published example value"}},
+		{name: "dev mode allows missing secret", modify: func(c *config.Config) { c.JWTSecret = ""; c.DevMode = true }},
+		{name: "port", modify: func(c *config.Config) { c.ServerPort = "http" }, want: []string{`SERVER_PORT: must be a port number between 1 and 65535, got "http"`}},
+		{name: "duration", modify: func(c *config.Config) { c.AccessTokenTTL = 0 }, want: []string{"JWT_ACCESS_TTL: must be greater than 0, got 0s"}},
+		{name: "driver needs dsn", modify: func(c *config.Config) { c.DBDriver = "postgres" }, want: []string{"DB_DSN: is required when DB_DRIVER is postgres"}},
+		{name: "unknown choice", modify: func(c *config.Config) { c.CodeStore = "redis" }, want: []string{`CODE_STORE: must be one of memory, sqlite, database, got "redis"`}},
+		{name: "origin", modify: func(c *config.Config) { c.CORSAllowedOrigins = []string{"example.com"} }, want: []string{`CORS_ALLOWED_ORIGINS: "example.com" is not an origin`}},
+		{name: "page sizes", modify: func(c *config.Config) { c.DefaultPageSize = c.MaxPageSize + 1 }, want: []string{"PAGE_SIZE_DEFAULT: must be between 1 and PAGE_SIZE_MAX"}},
+		{name: "backup dir", modify: func(c *config.Config) { c.BackupInterval = time.Hour; c.BackupDir = "" }, want: []string{"BACKUP_DIR: is required when BACKUP_INTERVAL is set"}},
+		{
+			name:   "all problems at once",
+			modify: func(c *config.Config) { c.ServerPort = "0"; c.LogLevel = "loud"; c.SMSMaxAttempts = 0 },
+			want:   []string{"SERVER_PORT:", `LOG_LEVEL: must be one of debug, info, warn, error, got "loud"`, "SMS_MAX_ATTEMPTS: must be at least 1, got 0"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Defaults()
+			cfg.JWTSecret = "test-secret"
+			tc.modify(cfg)
+			err := cfg.Validate()
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("want errors %q, got none", tc.want)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid configuration:\n") {
+				t.Fatalf("error %q lacks the heading", err)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q, want it to mention %q", err, w)
+				}
+			}
+		})
+	}
+}
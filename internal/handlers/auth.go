@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"digital-community/internal/auth"
+	"digital-community/internal/logging"
+	"digital-community/internal/middleware"
+	"digital-community/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func (h *Users) generateToken(user models.User) (string, error) {
+	jti, err := auth.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := h.now()
+	claims := middleware.Claims{
+		UserID:       int(user.ID),
+		UserName:     user.UserName,
+		NickName:     user.NickName,
+		Phone:        user.Phone,
+		Role:         models.NormalizeRole(user.Role),
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(int(user.ID)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.cfg.AccessTokenTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.cfg.JWTSecret))
+}
+
+func (h *Users) issueTokens(user models.User, familyId string) (string, string, error) {
+	accessToken, err := h.generateToken(user)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := h.sessions.CreateRefreshToken(int(user.ID), familyId, h.cfg.RefreshTokenTTL)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+const passwordResetCodePrefix = "reset:"
+
+type passwordResetClaims struct {
+	UserID       int `json:"userId"`
+	TokenVersion int `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+// Reset tokens are signed with a key derived from the JWT secret so they can
+// never be accepted by AuthMiddleware as access tokens.
+func (h *Users) passwordResetKey() []byte {
+	return []byte(h.cfg.JWTSecret + ":password-reset")
+}
+
+func (h *Users) generateResetToken(user models.User) (string, error) {
+	jti, err := auth.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := h.now()
+	claims := passwordResetClaims{
+		UserID:       int(user.ID),
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(int(user.ID)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.cfg.PasswordResetTTL)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(h.passwordResetKey())
+}
+
+func (h *Users) parseResetToken(tokenString string) (*passwordResetClaims, error) {
+	claims := &passwordResetClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return h.passwordResetKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (h *Users) PasswordSMSCode(c *gin.Context) {
+	phone, ok := h.activePhoneQuery(c)
+	if !ok {
+		return
+	}
+	h.sendSMSCode(c, passwordResetCodePrefix+phone, phone)
+}
+
+// PasswordVerify shares the login guard with PhoneLogin, so wrong reset
+// codes count towards the same per-account and per-IP lockout.
+func (h *Users) PasswordVerify(c *gin.Context) {
+	var req PasswordVerifyRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+
+	ip := c.ClientIP()
+	if wait := h.guard.Wait(nil, ip); wait > 0 {
+		respondLoginLocked(c, wait)
+		return
+	}
+	var user models.User
+	if err := h.requestDB(c).Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		_ = h.guard.RecordFailure(nil, ip)
+		fail(c, 500, middleware.ClassInvalidSMSCode, "验证码错误或已过期")
+		return
+	}
+	if wait := h.guard.Wait(&user, ip); wait > 0 {
+		respondLoginLocked(c, wait)
+		return
+	}
+	key := passwordResetCodePrefix + req.Phone
+	if !h.verifySMSCode(c, key, req.SMSCode) {
+		_ = h.guard.RecordFailure(&user, ip)
+		fail(c, 500, middleware.ClassInvalidSMSCode, "验证码错误或已过期")
+		return
+	}
+	if !user.IsActive() {
+		fail(c, 500, middleware.ClassAccountDisabled, "账号不存在或已停用")
+		return
+	}
+
+	token, err := h.generateResetToken(user)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "验证成功", Data: gin.H{
+		"resetToken": token,
+		"expiresIn":  int(h.cfg.PasswordResetTTL.Seconds()),
+	}})
+}
+
+func (h *Users) PasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+
+	claims, err := h.parseResetToken(req.ResetToken)
+	if err != nil {
+		fail(c, 500, middleware.ClassResetTokenInvalid, "重置链接已失效，请重新验证")
+		return
+	}
+	var user models.User
+	if err := h.requestDB(c).First(&user, claims.UserID).Error; err != nil || !user.IsActive() {
+		fail(c, 500, middleware.ClassAccountDisabled, "账号不存在或已停用")
+		return
+	}
+
+	hashed, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	// The password and token version change together, and only if the
+	// version still matches the token, so a reset token works exactly once
+	// even when two resets race.
+	if err := h.sessions.ResetPassword(int(user.ID), claims.TokenVersion, hashed); err != nil {
+		if errors.Is(err, auth.ErrTokenVersionChanged) {
+			fail(c, 500, middleware.ClassResetTokenInvalid, "重置链接已失效，请重新验证")
+			return
+		}
+		logging.L(c).Error("password reset failed", "target_user_id", user.ID, "error", err)
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	if err := h.guard.Unlock(int(user.ID)); err != nil {
+		logging.L(c).Error("unlock after password reset failed", "target_user_id", user.ID, "error", err)
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "密码已重置，请重新登录"})
+}
+
+func (h *Users) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+
+	record, err := h.sessions.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		fail(c, 401, middleware.ClassUnauthorized, "登录已过期，请重新登录")
+		return
+	}
+
+	var user models.User
+	if err := h.requestDB(c).First(&user, record.UserId).Error; err != nil {
+		fail(c, 401, middleware.ClassUnauthorized, "用户不存在")
+		return
+	}
+	if !user.IsActive() {
+		fail(c, 401, middleware.ClassUnauthorized, "账号已停用")
+		return
+	}
+
+	token, refreshToken, err := h.issueTokens(user, record.FamilyId)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "生成token失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Token: token, RefreshToken: refreshToken})
+}
+
+func (h *Users) Logout(c *gin.Context) {
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	expiresAt := h.now().Add(h.cfg.AccessTokenTTL)
+	if v, ok := c.Get("tokenExpiresAt"); ok {
+		expiresAt = v.(time.Time)
+	}
+	if err := h.sessions.RevokeToken(c.GetString("tokenId"), c.GetInt("userId"), expiresAt); err != nil {
+		fail(c, 500, middleware.ClassInternal, "退出失败")
+		return
+	}
+	if req.RefreshToken != "" {
+		_ = h.sessions.RevokeRefreshToken(req.RefreshToken)
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "退出成功"})
+}
+
+func (h *Users) LogoutAll(c *gin.Context) {
+	if err := h.sessions.RevokeUser(c.GetInt("userId")); err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "已退出全部登录"})
+}
+
+func (h *Users) ForceLogout(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userId <= 0 {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+	var user models.User
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "用户不存在")
+		return
+	}
+	if err := h.sessions.RevokeUser(userId); err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
+}
+
+func (h *Users) UserUnlock(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userId <= 0 {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+	var user models.User
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "用户不存在")
+		return
+	}
+	if err := h.guard.Unlock(userId); err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "解锁成功"})
+}
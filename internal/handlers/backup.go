@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"digital-community/internal/backup"
+	"digital-community/internal/logging"
+	"digital-community/internal/middleware"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *System) BackupCreate(c *gin.Context) {
+	path, err := backup.Create(c.Request.Context(), h.db, h.cfg.UploadRoot, h.cfg.BackupDir)
+	if err != nil {
+		logging.L(c).Error("backup failed", "error", err)
+		fail(c, 500, middleware.ClassInternal, "备份失败")
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "备份失败")
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Data: backup.Info{
+		Name:      filepath.Base(path),
+		Size:      info.Size(),
+		CreatedAt: info.ModTime(),
+	}})
+}
+
+func (h *System) BackupList(c *gin.Context) {
+	list, err := backup.List(h.cfg.BackupDir)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
+		return
+	}
+	respondList(c, "查询成功", list, int64(len(list)))
+}
+
+func (h *System) BackupDownload(c *gin.Context) {
+	path, err := backup.Path(h.cfg.BackupDir, c.Param("name"))
+	if err != nil {
+		fail(c, 404, middleware.ClassNotFound, "备份不存在")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		fail(c, 404, middleware.ClassNotFound, "备份不存在")
+		return
+	}
+	c.FileAttachment(path, filepath.Base(path))
+}
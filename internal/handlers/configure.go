@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"digital-community/internal/auth"
+	"digital-community/internal/config"
+	"digital-community/internal/migrations"
+	"digital-community/internal/sms"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Deps are what every handler service is built from. Now defaults to
+// time.Now; tests can pin it. New also hands it to the session store, login
+// guard, SMS throttles and code store, so expiry and lockouts follow it too.
+type Deps struct {
+	DB     *gorm.DB
+	Config *config.Config
+	Now    func() time.Time
+}
+
+type base struct {
+	db  *gorm.DB
+	cfg *config.Config
+	now func() time.Time
+}
+
+func newBase(deps Deps) base {
+	now := deps.Now
+	if now == nil {
+		now = time.Now
+	}
+	return base{db: deps.DB, cfg: deps.Config, now: now}
+}
+
+// Users handles login, SMS codes, password reset, profiles and user admin.
+type Users struct {
+	base
+	sessions      *auth.SessionStore
+	guard         *auth.LoginGuard
+	smsSender     sms.Sender
+	phoneThrottle *sms.Throttle
+	ipThrottle    *sms.Throttle
+	codeStore     sms.CodeStore
+}
+
+// Press handles news, categories, comments, notices and the rotation banner.
+type Press struct{ base }
+
+// Activity handles activities, registrations and neighbor posts.
+type Activity struct{ base }
+
+// Media handles uploads, image and file listings and thumbnails.
+type Media struct{ base }
+
+// Green handles the green-living data cards, quiz and chart series.
+type Green struct{ base }
+
+// System handles health, version and backup endpoints.
+type System struct{ base }
+
+// Search handles the unified search box. indexed is set when the database
+// has the FTS5 search index.
+type Search struct {
+	base
+	indexed bool
+}
+
+// Handlers is the set of services router.Setup registers.
+type Handlers struct {
+	Sessions *auth.SessionStore
+	Users    *Users
+	Press    *Press
+	Activity *Activity
+	Media    *Media
+	Green    *Green
+	System   *System
+	Search   *Search
+}
+
+// New fails when the SMS provider is misconfigured rather than silently
+// writing verification codes to the log.
+func New(deps Deps) (*Handlers, error) {
+	cfg := deps.Config
+	sender, err := sms.NewSender(cfg.SMSProvider, cfg.SMSLogFile, cfg.SMSGatewayURL, cfg.SMSGatewayKey, cfg.SMSTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("sms sender: %w", err)
+	}
+	b := newBase(deps)
+	sessions := auth.NewSessionStore(deps.DB, cfg.UserStateCacheTTL, b.now)
+	var codeStore sms.CodeStore
+	switch cfg.CodeStore {
+	case "sqlite", "database":
+		codeStore = sms.NewDBCodeStore(deps.DB, b.now)
+	default:
+		codeStore = sms.NewMemoryCodeStore(time.Minute, b.now)
+	}
+
+	return &Handlers{
+		Sessions: sessions,
+		Users: &Users{
+			base:     b,
+			sessions: sessions,
+			guard: auth.NewLoginGuard(deps.DB, auth.GuardPolicy{
+				DelayAfter:    cfg.LoginDelayAfter,
+				MaxFailures:   cfg.LoginMaxFailures,
+				LockDuration:  cfg.LoginLockDuration,
+				IPMaxFailures: cfg.LoginIPMaxFailures,
+				IPWindow:      cfg.LoginIPWindow,
+			}, b.now),
+			smsSender:     sender,
+			phoneThrottle: sms.NewThrottle(1, cfg.SMSPhoneInterval, b.now),
+			ipThrottle:    sms.NewThrottle(cfg.SMSIPLimit, cfg.SMSIPWindow, b.now),
+			codeStore:     codeStore,
+		},
+		Press:    &Press{b},
+		Activity: &Activity{b},
+		Media:    NewMedia(deps),
+		Green:    &Green{b},
+		System:   &System{b},
+		Search:   &Search{base: b, indexed: migrations.HasSearchIndex(deps.DB)},
+	}, nil
+}
+
+// NewMedia builds the media service alone, for commands that only touch the
+// upload directory. DB may be nil.
+func NewMedia(deps Deps) *Media {
+	return &Media{newBase(deps)}
+}
+
+// Close stops background work started by New.
+func (h *Handlers) Close() {
+	if mem, ok := h.Users.codeStore.(*sms.MemoryCodeStore); ok {
+		mem.Close()
+	}
+}
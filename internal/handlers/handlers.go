@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"bytes"
-	"digital-community/internal/config"
+	"context"
+	"crypto/rand"
+	"digital-community/internal/auth"
+	"digital-community/internal/logging"
+	"digital-community/internal/metrics"
+	"digital-community/internal/middleware"
 	"digital-community/internal/models"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -12,7 +18,8 @@ import (
 	_ "image/gif"
 	"image/jpeg"
 	_ "image/png"
-	"math/rand"
+	"math/big"
+	mrand "math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -20,20 +27,19 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	_ "golang.org/x/image/webp"
 	"gorm.io/gorm"
 )
 
 type Response struct {
-	Code  int         `json:"code"`
-	Msg   string      `json:"msg"`
-	Data  interface{} `json:"data,omitempty"`
-	Token string      `json:"token,omitempty"`
+	Code         int         `json:"code"`
+	Msg          string      `json:"msg"`
+	Data         interface{} `json:"data,omitempty"`
+	Token        string      `json:"token,omitempty"`
+	RefreshToken string      `json:"refreshToken,omitempty"`
 }
 
 func respondList(c *gin.Context, msg string, data interface{}, total int64) {
@@ -45,54 +51,67 @@ func respondList(c *gin.Context, msg string, data interface{}, total int64) {
 	})
 }
 
-type smsCodeRecord struct {
-	Code      string
-	ExpiresAt time.Time
+var phonePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+func genSMSCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(9000))
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(1000 + int(n.Int64()))
 }
 
-var smsCodeStore = struct {
-	sync.RWMutex
-	Data map[string]smsCodeRecord
-}{
-	Data: map[string]smsCodeRecord{},
+// requestDB binds queries to the request context so failed queries are
+// logged with the request ID, route and user.
+func (h *base) requestDB(c *gin.Context) *gorm.DB {
+	return h.db.WithContext(c.Request.Context())
 }
 
-var smsRand = rand.New(rand.NewSource(time.Now().UnixNano()))
-var phonePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+// bindJSON records binding failures on the context so strict response mode
+// can report them per field.
+func bindJSON(c *gin.Context, obj interface{}) error {
+	err := c.ShouldBindJSON(obj)
+	if err != nil {
+		_ = c.Error(err).SetType(gin.ErrorTypeBind)
+	}
+	return err
+}
 
-func genSMSCode() string {
-	return strconv.Itoa(1000 + smsRand.Intn(9000))
+// likeEscaper escapes LIKE wildcards for use with ESCAPE '!'. A backslash
+// would need different quoting on MySQL and PostgreSQL.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// escapeLike makes s match literally inside a LIKE pattern; the query must
+// say ESCAPE '!'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
 }
 
-func setSMSCode(phone, code string) {
-	smsCodeStore.Lock()
-	defer smsCodeStore.Unlock()
-	smsCodeStore.Data[phone] = smsCodeRecord{
-		Code:      code,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-	}
+// fail writes every error reply: code is the body code existing clients
+// expect (500 for most failures) and class is the HTTP status and errorCode
+// strict response mode reports instead.
+func fail(c *gin.Context, code int, class middleware.ErrorClass, msg string) {
+	middleware.SetErrorClass(c, class)
+	c.JSON(http.StatusOK, Response{Code: code, Msg: msg})
 }
 
-func verifySMSCode(phone, code string) bool {
-	smsCodeStore.RLock()
-	rec, ok := smsCodeStore.Data[phone]
-	smsCodeStore.RUnlock()
-	if !ok {
-		return false
-	}
-	if time.Now().After(rec.ExpiresAt) {
-		smsCodeStore.Lock()
-		delete(smsCodeStore.Data, phone)
-		smsCodeStore.Unlock()
+func (h *Users) setSMSCode(phone, code string) error {
+	return h.codeStore.Set(phone, code, h.cfg.SMSCodeTTL)
+}
+
+func (h *Users) verifySMSCode(c *gin.Context, key, code string) bool {
+	ok, err := h.codeStore.Verify(key, code, h.cfg.SMSMaxAttempts)
+	if err != nil {
+		logging.L(c).Error("verify sms code failed", "error", err)
 		return false
 	}
-	return rec.Code == code
+	return ok
 }
 
-func clearSMSCode(phone string) {
-	smsCodeStore.Lock()
-	defer smsCodeStore.Unlock()
-	delete(smsCodeStore.Data, phone)
+func (h *Users) clearSMSCode(c *gin.Context, key string) {
+	if err := h.codeStore.Delete(key); err != nil {
+		logging.L(c).Error("clear sms code failed", "error", err)
+	}
 }
 
 type userInfoResp struct {
@@ -108,6 +127,7 @@ type userInfoResp struct {
 	IDCard       string  `json:"idCard"`
 	Address      string  `json:"address"`
 	Introduction string  `json:"introduction"`
+	Role         string  `json:"role"`
 }
 
 func buildUserInfoResp(user models.User) userInfoResp {
@@ -124,6 +144,7 @@ func buildUserInfoResp(user models.User) userInfoResp {
 		IDCard:       user.IDCard,
 		Address:      user.Address,
 		Introduction: user.Introduction,
+		Role:         models.NormalizeRole(user.Role),
 	}
 }
 
@@ -200,136 +221,221 @@ func buildActivityItem(activity models.Activity) gin.H {
 	}
 }
 
-func parsePaging(c *gin.Context) (int, int) {
+func (h *base) parsePaging(c *gin.Context) (int, int) {
 	pageNum, _ := strconv.Atoi(c.DefaultQuery("pageNum", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
 	if pageNum < 1 {
 		pageNum = 1
 	}
 	if pageSize < 1 {
-		pageSize = 10
+		pageSize = h.cfg.DefaultPageSize
+	}
+	if pageSize > h.cfg.MaxPageSize {
+		pageSize = h.cfg.MaxPageSize
 	}
 	return pageNum, pageSize
 }
 
-func PhoneLogin(c *gin.Context) {
-	var req struct {
-		Phone      string `json:"phone" binding:"required"`
-		SMSCode    string `json:"smsCode"`
-		LegacyCode string `json:"SMSCode"`
+// parseMediaPaging caps page size lower for image and file listings, which
+// walk the upload directory on every request.
+func (h *base) parseMediaPaging(c *gin.Context) (int, int) {
+	pageNum, pageSize := h.parsePaging(c)
+	if pageSize > h.cfg.MediaMaxPageSize {
+		pageSize = h.cfg.MediaMaxPageSize
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	return pageNum, pageSize
+}
+
+func (h *Users) PhoneLogin(c *gin.Context) {
+	var req PhoneLoginRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.SMSCode == "" {
 		req.SMSCode = req.LegacyCode
 	}
 	if req.SMSCode == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if !phonePattern.MatchString(req.Phone) {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号格式错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "手机号格式错误")
 		return
 	}
-	if !verifySMSCode(req.Phone, req.SMSCode) {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "验证码错误或已过期"})
+
+	ip := c.ClientIP()
+	if wait := h.guard.Wait(nil, ip); wait > 0 {
+		respondLoginLocked(c, wait)
 		return
 	}
 
 	var user models.User
-	if err := config.DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号未注册"})
+	if err := h.requestDB(c).Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		_ = h.guard.RecordFailure(nil, ip)
+		fail(c, 500, middleware.ClassNotFound, "手机号未注册")
+		return
+	}
+	if wait := h.guard.Wait(&user, ip); wait > 0 {
+		respondLoginLocked(c, wait)
+		return
+	}
+	if !h.verifySMSCode(c, req.Phone, req.SMSCode) {
+		_ = h.guard.RecordFailure(&user, ip)
+		fail(c, 500, middleware.ClassInvalidSMSCode, "验证码错误或已过期")
+		return
+	}
+	if !user.IsActive() {
+		fail(c, 500, middleware.ClassAccountDisabled, "账号已停用")
 		return
 	}
 
-	token, err := generateToken(int(user.ID), user.UserName, user.NickName, user.Phone)
+	token, refreshToken, err := h.issueTokens(user, "")
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "生成token失败"})
+		fail(c, 500, middleware.ClassInternal, "生成token失败")
 		return
 	}
-	clearSMSCode(req.Phone)
+	if err := h.guard.RecordSuccess(&user, ip); err != nil {
+		logging.L(c).Error("record login failed", "target_user_id", user.ID, "error", err)
+	}
 
-	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Token: token})
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Token: token, RefreshToken: refreshToken})
 }
 
-func Login(c *gin.Context) {
-	var req struct {
-		UserName       string `json:"userName" binding:"required"`
-		Password       string `json:"password"`
-		LegacyPassword string `json:"passWord"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Users) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Password == "" {
 		req.Password = req.LegacyPassword
 	}
 	if req.Password == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+
+	ip := c.ClientIP()
+	if wait := h.guard.Wait(nil, ip); wait > 0 {
+		respondLoginLocked(c, wait)
 		return
 	}
 
 	var user models.User
-	result := config.DB.Where("user_name = ? AND pass_word = ?", req.UserName, req.Password).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户名或密码错误"})
+	if err := h.requestDB(c).Where("user_name = ?", req.UserName).First(&user).Error; err != nil {
+		auth.BurnPasswordCheck(req.Password)
+		_ = h.guard.RecordFailure(nil, ip)
+		fail(c, 500, middleware.ClassInvalidCredentials, "用户名或密码错误")
+		return
+	}
+	if wait := h.guard.Wait(&user, ip); wait > 0 {
+		respondLoginLocked(c, wait)
 		return
 	}
+	ok, needsRehash := auth.CheckPassword(user.PassWord, req.Password)
+	if !ok {
+		_ = h.guard.RecordFailure(&user, ip)
+		fail(c, 500, middleware.ClassInvalidCredentials, "用户名或密码错误")
+		return
+	}
+	if !user.IsActive() {
+		fail(c, 500, middleware.ClassAccountDisabled, "账号已停用")
+		return
+	}
+	if needsRehash {
+		if hashed, err := auth.HashPassword(req.Password); err == nil {
+			h.requestDB(c).Model(&models.User{}).Where("id = ? AND pass_word = ?", user.ID, user.PassWord).Update("pass_word", hashed)
+		}
+	}
 
-	token, err := generateToken(int(user.ID), user.UserName, user.NickName, user.Phone)
+	token, refreshToken, err := h.issueTokens(user, "")
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "生成token失败"})
+		fail(c, 500, middleware.ClassInternal, "生成token失败")
 		return
 	}
+	if err := h.guard.RecordSuccess(&user, ip); err != nil {
+		logging.L(c).Error("record login failed", "target_user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Token: token, RefreshToken: refreshToken})
+}
+
+func respondLoginLocked(c *gin.Context, wait time.Duration) {
+	seconds := int(wait.Seconds()) + 1
+	msg := fmt.Sprintf("登录尝试过于频繁，请%d秒后再试", seconds)
+	if seconds > 60 {
+		msg = fmt.Sprintf("账号已临时锁定，请%d分钟后再试", (seconds+59)/60)
+	}
+	fail(c, 429, middleware.ClassTooManyRequests, msg)
+}
 
-	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功", Token: token})
+func (h *Users) SMSCode(c *gin.Context) {
+	phone, ok := h.activePhoneQuery(c)
+	if !ok {
+		return
+	}
+	h.sendSMSCode(c, phone, phone)
 }
 
-func SMSCode(c *gin.Context) {
+func (h *Users) activePhoneQuery(c *gin.Context) (string, bool) {
 	phone := c.Query("phone")
 	if phone == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号不能为空"})
-		return
+		fail(c, 500, middleware.ClassInvalidParams, "手机号不能为空")
+		return "", false
 	}
 	if !phonePattern.MatchString(phone) {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号格式错误"})
-		return
+		fail(c, 500, middleware.ClassInvalidParams, "手机号格式错误")
+		return "", false
 	}
 	var count int64
-	config.DB.Model(&models.User{}).Where("phone = ?", phone).Count(&count)
+	h.requestDB(c).Model(&models.User{}).Where("phone = ? AND status = ? AND del_flag = ?", phone, models.UserStatusNormal, models.DelFlagExists).Count(&count)
 	if count == 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号未注册"})
+		fail(c, 500, middleware.ClassNotFound, "手机号未注册")
+		return "", false
+	}
+	return phone, true
+}
+
+// sendSMSCode stores the code under key, which lets different flows (login,
+// password reset) keep separate codes for the same phone.
+func (h *Users) sendSMSCode(c *gin.Context, key, phone string) {
+	if ok, retryAfter := h.phoneThrottle.Allow(phone); !ok {
+		fail(c, 429, middleware.ClassTooManyRequests, fmt.Sprintf("发送过于频繁，请%d秒后再试", int(retryAfter.Seconds())+1))
 		return
 	}
+	if ok, _ := h.ipThrottle.Allow(c.ClientIP()); !ok {
+		fail(c, 429, middleware.ClassTooManyRequests, "发送过于频繁，请稍后再试")
+		return
+	}
+
 	code := genSMSCode()
-	setSMSCode(phone, code)
-	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Msg:  "请求成功",
-		Data: code,
-	})
-}
+	if code == "" {
+		fail(c, 500, middleware.ClassInternal, "验证码生成失败")
+		return
+	}
+	if err := h.setSMSCode(key, code); err != nil {
+		fail(c, 500, middleware.ClassInternal, "验证码生成失败")
+		return
+	}
+	if err := h.smsSender.Send(c.Request.Context(), phone, code); err != nil {
+		h.clearSMSCode(c, key)
+		logging.L(c).Error("sms send failed", "phone", logging.MaskPhone(phone), "error", err)
+		fail(c, 500, middleware.ClassInternal, "短信发送失败")
+		return
+	}
 
-func Register(c *gin.Context) {
-	var req struct {
-		Avatar       string `json:"avatar"`
-		UserName     string `json:"userName" binding:"required"`
-		NickName     string `json:"nickName"`
-		Password     string `json:"password"`
-		PassWord     string `json:"passWord"`
-		PhoneNumber  string `json:"phoneNumber"`
-		Phonenumber  string `json:"phonenumber"`
-		Sex          string `json:"sex" binding:"required"`
-		Email        string `json:"email"`
-		IDCard       string `json:"idCard"`
-		Address      string `json:"address"`
-		Introduction string `json:"introduction"`
+	resp := Response{Code: 200, Msg: "请求成功"}
+	if h.cfg.SMSDevMode {
+		resp.Data = code
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Users) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Password == "" {
@@ -339,33 +445,39 @@ func Register(c *gin.Context) {
 		req.PhoneNumber = req.Phonenumber
 	}
 	if req.Password == "" || req.PhoneNumber == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if !phonePattern.MatchString(req.PhoneNumber) {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号格式错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "手机号格式错误")
 		return
 	}
 	if req.Sex != "0" && req.Sex != "1" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "性别参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "性别参数错误")
 		return
 	}
 
 	var count int64
-	config.DB.Model(&models.User{}).Where("user_name = ?", req.UserName).Count(&count)
+	h.requestDB(c).Model(&models.User{}).Where("user_name = ?", req.UserName).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户名已存在"})
+		fail(c, 500, middleware.ClassConflict, "用户名已存在")
 		return
 	}
-	config.DB.Model(&models.User{}).Where("phone = ?", req.PhoneNumber).Count(&count)
+	h.requestDB(c).Model(&models.User{}).Where("phone = ?", req.PhoneNumber).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号已注册"})
+		fail(c, 500, middleware.ClassConflict, "手机号已注册")
+		return
+	}
+
+	hashed, err := auth.HashPassword(req.Password)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "注册失败")
 		return
 	}
 
 	user := models.User{
 		UserName:     req.UserName,
-		PassWord:     req.Password,
+		PassWord:     hashed,
 		NickName:     req.NickName,
 		Phone:        req.PhoneNumber,
 		Sex:          req.Sex,
@@ -376,39 +488,33 @@ func Register(c *gin.Context) {
 		Introduction: req.Introduction,
 		Balance:      0,
 		Score:        0,
-		Status:       "0",
-		DelFlag:      "0",
+		Status:       models.UserStatusNormal,
+		DelFlag:      models.DelFlagExists,
+		Role:         models.RoleResident,
+	}
+	if err := h.requestDB(c).Create(&user).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "注册失败")
+		return
 	}
-	config.DB.Create(&user)
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func GetUserInfo(c *gin.Context) {
+func (h *Users) GetUserInfo(c *gin.Context) {
 	userId := c.GetInt("userId")
 	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户不存在"})
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 500, middleware.ClassNotFound, "用户不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "获取数据成功", Data: buildUserInfoResp(user)})
 }
 
-func UpdateUserInfo(c *gin.Context) {
+func (h *Users) UpdateUserInfo(c *gin.Context) {
 	userId := c.GetInt("userId")
-	var req struct {
-		Avatar       string `json:"avatar"`
-		NickName     string `json:"nickName" binding:"required"`
-		PhoneNumber  string `json:"phoneNumber"`
-		Phonenumber  string `json:"phonenumber"`
-		Sex          string `json:"sex"`
-		Email        string `json:"email"`
-		IDCard       string `json:"idCard"`
-		Address      string `json:"address"`
-		Introduction string `json:"introduction"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req UpdateUserInfoRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.PhoneNumber == "" {
@@ -416,12 +522,12 @@ func UpdateUserInfo(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户不存在"})
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 500, middleware.ClassNotFound, "用户不存在")
 		return
 	}
 
-	config.DB.Model(&user).Updates(map[string]interface{}{
+	h.requestDB(c).Model(&user).Updates(map[string]interface{}{
 		"nick_name":    req.NickName,
 		"phone":        req.PhoneNumber,
 		"sex":          req.Sex,
@@ -435,39 +541,56 @@ func UpdateUserInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func ResetPwd(c *gin.Context) {
+func (h *Users) ResetPwd(c *gin.Context) {
 	userId := c.GetInt("userId")
-	var req struct {
-		OldPassword string `json:"oldPassword" binding:"required"`
-		NewPassword string `json:"newPassword" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req ResetPwdRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户不存在"})
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 500, middleware.ClassNotFound, "用户不存在")
 		return
 	}
-	if user.PassWord != req.OldPassword {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "原密码错误"})
+	if ok, _ := auth.CheckPassword(user.PassWord, req.OldPassword); !ok {
+		fail(c, 500, middleware.ClassWrongPassword, "原密码错误")
 		return
 	}
 
-	config.DB.Model(&models.User{}).Where("id = ?", userId).Update("pass_word", req.NewPassword)
+	hashed, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
+	// Bumping the token version signs out every session, including one an
+	// attacker may hold, which is usually why the password is being changed.
+	if err := h.sessions.ResetPassword(userId, user.TokenVersion, hashed); err != nil {
+		if !errors.Is(err, auth.ErrTokenVersionChanged) {
+			logging.L(c).Error("password change failed", "error", err)
+		}
+		fail(c, 500, middleware.ClassInternal, "操作失败")
+		return
+	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func UserList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Users) UserList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 
 	var users []models.User
 	var total int64
-	config.DB.Model(&models.User{}).Count(&total)
+	query := h.requestDB(c).Model(&models.User{})
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
 
-	config.DB.Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("id DESC").Find(&users)
+	query.Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("id DESC").Find(&users)
 
 	items := make([]gin.H, 0, len(users))
 	for _, v := range users {
@@ -484,44 +607,51 @@ func UserList(c *gin.Context) {
 			"balance":      v.Balance,
 			"score":        v.Score,
 			"status":       v.Status,
+			"role":         models.NormalizeRole(v.Role),
+			"loginDate":    v.LoginDate,
+			"loginIp":      v.IP,
+			"locked":       v.LockedUntil != nil && v.LockedUntil.After(h.now()),
 			"createTime":   v.CreatedAt.Format("2006-01-02 15:04:05"),
 		})
 	}
 	respondList(c, "获取成功", items, total)
 }
 
-func UserCreate(c *gin.Context) {
-	var req struct {
-		UserName     string `json:"userName" binding:"required"`
-		Password     string `json:"password" binding:"required"`
-		NickName     string `json:"nickName"`
-		Phone        string `json:"phone" binding:"required"`
-		Sex          string `json:"sex"`
-		Email        string `json:"email"`
-		Avatar       string `json:"avatar"`
-		Address      string `json:"address"`
-		Introduction string `json:"introduction"`
+func (h *Users) UserCreate(c *gin.Context) {
+	var req UserCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	if req.Role == "" {
+		req.Role = models.RoleResident
+	}
+	if !models.IsValidRole(req.Role) {
+		fail(c, 500, middleware.ClassInvalidParams, "角色参数错误")
 		return
 	}
 
 	var count int64
-	config.DB.Model(&models.User{}).Where("user_name = ?", req.UserName).Count(&count)
+	h.requestDB(c).Model(&models.User{}).Where("user_name = ?", req.UserName).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "用户名已存在"})
+		fail(c, 500, middleware.ClassConflict, "用户名已存在")
 		return
 	}
-	config.DB.Model(&models.User{}).Where("phone = ?", req.Phone).Count(&count)
+	h.requestDB(c).Model(&models.User{}).Where("phone = ?", req.Phone).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "手机号已存在"})
+		fail(c, 500, middleware.ClassConflict, "手机号已存在")
+		return
+	}
+
+	hashed, err := auth.HashPassword(req.Password)
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
 		return
 	}
 
 	user := models.User{
 		UserName:     req.UserName,
-		PassWord:     req.Password,
+		PassWord:     hashed,
 		NickName:     req.NickName,
 		Phone:        req.Phone,
 		Sex:          req.Sex,
@@ -531,40 +661,43 @@ func UserCreate(c *gin.Context) {
 		Introduction: req.Introduction,
 		Balance:      0,
 		Score:        0,
-		Status:       "0",
-		DelFlag:      "0",
+		Status:       models.UserStatusNormal,
+		DelFlag:      models.DelFlagExists,
+		Role:         req.Role,
+	}
+	if err := h.requestDB(c).Create(&user).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
 	}
-	config.DB.Create(&user)
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: user.ID})
 }
 
-func UserUpdate(c *gin.Context) {
+func (h *Users) UserUpdate(c *gin.Context) {
 	id := c.Param("id")
 	userId, err := strconv.Atoi(id)
 	if err != nil || userId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
-	var req struct {
-		NickName     string `json:"nickName"`
-		Phone        string `json:"phone"`
-		Sex          string `json:"sex"`
-		Email        string `json:"email"`
-		Avatar       string `json:"avatar"`
-		Address      string `json:"address"`
-		Introduction string `json:"introduction"`
-		Status       string `json:"status"`
+	var req UserUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+	if req.Role != "" && !models.IsValidRole(req.Role) {
+		fail(c, 500, middleware.ClassInvalidParams, "角色参数错误")
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	if req.Status != "" && req.Status != models.UserStatusNormal && req.Status != models.UserStatusDisabled {
+		fail(c, 500, middleware.ClassInvalidParams, "状态参数错误")
 		return
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "用户不存在"})
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "用户不存在")
 		return
 	}
 
@@ -593,43 +726,48 @@ func UserUpdate(c *gin.Context) {
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
+	if req.Role != "" {
+		updates["role"] = req.Role
+	}
 
-	config.DB.Model(&user).Updates(updates)
+	h.requestDB(c).Model(&user).Updates(updates)
+	h.sessions.InvalidateUser(int(user.ID))
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func UserDelete(c *gin.Context) {
+func (h *Users) UserDelete(c *gin.Context) {
 	id := c.Param("id")
 	userId, err := strconv.Atoi(id)
 	if err != nil || userId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "用户不存在"})
+	if err := h.requestDB(c).First(&user, userId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "用户不存在")
 		return
 	}
 
-	config.DB.Delete(&user)
+	if err := h.sessions.RevokeUser(int(user.ID)); err != nil {
+		logging.L(c).Error("revoke sessions for deleted user failed", "target_user_id", user.ID, "error", err)
+	}
+	h.requestDB(c).Model(&user).Update("del_flag", models.DelFlagDeleted)
+	h.requestDB(c).Delete(&user)
+	h.sessions.InvalidateUser(int(user.ID))
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func Logout(c *gin.Context) {
-	c.JSON(http.StatusOK, Response{Code: 200, Msg: "退出成功"})
-}
-
-func RotationList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Press) RotationList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 	rtype := c.Query("type")
 	if rtype == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	var rotations []models.Rotation
-	query := config.DB.Model(&models.Rotation{}).Where("type = ?", rtype)
+	query := h.requestDB(c).Model(&models.Rotation{}).Where("type = ?", rtype)
 
 	var total int64
 	query.Count(&total)
@@ -648,15 +786,10 @@ func RotationList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func RotationCreate(c *gin.Context) {
-	var req struct {
-		Title   string `json:"title" binding:"required"`
-		PicPath string `json:"picPath"`
-		Link    string `json:"link"`
-		Type    int    `json:"type" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Press) RotationCreate(c *gin.Context) {
+	var req RotationCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	rotation := models.Rotation{
@@ -666,26 +799,23 @@ func RotationCreate(c *gin.Context) {
 		Type:    req.Type,
 		Status:  "0",
 	}
-	config.DB.Create(&rotation)
+	if err := h.requestDB(c).Create(&rotation).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
+	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: rotation.ID})
 }
 
-func RotationUpdate(c *gin.Context) {
+func (h *Press) RotationUpdate(c *gin.Context) {
 	id := c.Param("id")
 	rotationId, err := strconv.Atoi(id)
 	if err != nil || rotationId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Title   string `json:"title"`
-		PicPath string `json:"picPath"`
-		Link    string `json:"link"`
-		Type    int    `json:"type"`
-		Status  string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req RotationUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	updates := map[string]interface{}{}
@@ -704,42 +834,42 @@ func RotationUpdate(c *gin.Context) {
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
-	result := config.DB.Model(&models.Rotation{}).Where("id = ?", rotationId).Updates(updates)
+	result := h.requestDB(c).Model(&models.Rotation{}).Where("id = ?", rotationId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "轮播图不存在"})
+		fail(c, 404, middleware.ClassNotFound, "轮播图不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func RotationDelete(c *gin.Context) {
+func (h *Press) RotationDelete(c *gin.Context) {
 	id := c.Param("id")
 	rotationId, err := strconv.Atoi(id)
 	if err != nil || rotationId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.Rotation{}, rotationId)
+	result := h.requestDB(c).Delete(&models.Rotation{}, rotationId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "轮播图不存在"})
+		fail(c, 404, middleware.ClassNotFound, "轮播图不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func PressCategoryList(c *gin.Context) {
+func (h *Press) PressCategoryList(c *gin.Context) {
 	var categories []models.PressCategory
 	var total int64
-	config.DB.Model(&models.PressCategory{}).Count(&total)
-	config.DB.Find(&categories)
+	h.requestDB(c).Model(&models.PressCategory{}).Count(&total)
+	h.requestDB(c).Find(&categories)
 	items := make([]gin.H, 0, len(categories))
 	for _, v := range categories {
 		items = append(items, gin.H{"id": v.ID, "name": v.Name, "sort": v.Sort, "appType": "smart_city"})
@@ -747,14 +877,10 @@ func PressCategoryList(c *gin.Context) {
 	respondList(c, "查询成功", items, total)
 }
 
-func PressCategoryCreate(c *gin.Context) {
-	var req struct {
-		Name   string `json:"name" binding:"required"`
-		Sort   int    `json:"sort"`
-		Status string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Press) PressCategoryCreate(c *gin.Context) {
+	var req PressCategoryCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	category := models.PressCategory{
@@ -762,24 +888,23 @@ func PressCategoryCreate(c *gin.Context) {
 		Sort:   req.Sort,
 		Status: req.Status,
 	}
-	config.DB.Create(&category)
+	if err := h.requestDB(c).Create(&category).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
+	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: category.ID})
 }
 
-func PressCategoryUpdate(c *gin.Context) {
+func (h *Press) PressCategoryUpdate(c *gin.Context) {
 	id := c.Param("id")
 	catId, err := strconv.Atoi(id)
 	if err != nil || catId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Name   string `json:"name"`
-		Sort   int    `json:"sort"`
-		Status string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req PressCategoryUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	updates := map[string]interface{}{}
@@ -792,68 +917,128 @@ func PressCategoryUpdate(c *gin.Context) {
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
-	result := config.DB.Model(&models.PressCategory{}).Where("id = ?", catId).Updates(updates)
+	result := h.requestDB(c).Model(&models.PressCategory{}).Where("id = ?", catId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "分类不存在"})
+		fail(c, 404, middleware.ClassNotFound, "分类不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func PressCategoryDelete(c *gin.Context) {
+func (h *Press) PressCategoryDelete(c *gin.Context) {
 	id := c.Param("id")
 	catId, err := strconv.Atoi(id)
 	if err != nil || catId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.PressCategory{}, catId)
+	result := h.requestDB(c).Delete(&models.PressCategory{}, catId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "分类不存在"})
+		fail(c, 404, middleware.ClassNotFound, "分类不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func PressNewsList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
-
-	var newsList []models.PressNews
-	var total int64
-	config.DB.Model(&models.PressNews{}).Count(&total)
-
-	config.DB.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&newsList)
-	items := make([]gin.H, 0, len(newsList))
-	for _, v := range newsList {
-		items = append(items, buildPressItem(v))
+func (h *Press) PressNewsList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
+	query, ok := h.filterNews(c, h.requestDB(c).Model(&models.PressNews{}))
+	if !ok {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
 	}
-	respondList(c, "查询成功", items, total)
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		query = query.Where("category_id = ?", categoryID)
+	}
+	h.respondNewsPage(c, query, pageNum, pageSize)
 }
 
-func PressCategoryNewsList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Press) PressCategoryNewsList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 	id := c.Query("id")
 	if id == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
+	query, ok := h.filterNews(c, h.requestDB(c).Model(&models.PressNews{}))
+	if !ok {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+	h.respondNewsPage(c, query.Where("category_id = ?", id), pageNum, pageSize)
+}
 
-	var newsList []models.PressNews
-	query := config.DB.Model(&models.PressNews{})
-	query = query.Where("category_id = ?", id)
+var newsSortColumns = map[string]string{
+	"publishDate": "publish_date",
+	"readNum":     "view_count",
+	"likeNum":     "like_num",
+}
 
+// filterNews applies the list filters shared by the home feed and the admin
+// table: status, top, hot, tag, keyword (title), startDate/endDate
+// (2006-01-02, inclusive) and sort/order. Pinned articles always come first.
+func (h *Press) filterNews(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	for _, column := range []string{"status", "top", "hot"} {
+		if v := c.Query(column); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		like := escapeLike(tag)
+		query = query.Where("(tags = ? OR tags LIKE ? ESCAPE '!' OR tags LIKE ? ESCAPE '!' OR tags LIKE ? ESCAPE '!')", tag, like+",%", "%,"+like, "%,"+like+",%")
+	}
+	if keyword := strings.TrimSpace(c.Query("keyword")); keyword != "" {
+		query = query.Where("title LIKE ? ESCAPE '!'", "%"+escapeLike(keyword)+"%")
+	}
+	if v := c.Query("startDate"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, false
+		}
+		query = query.Where("publish_date >= ?", start)
+	}
+	if v := c.Query("endDate"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, false
+		}
+		query = query.Where("publish_date < ?", end.AddDate(0, 0, 1))
+	}
+
+	column, ok := newsSortColumns[c.DefaultQuery("sort", "publishDate")]
+	if !ok {
+		return nil, false
+	}
+	direction := strings.ToUpper(c.DefaultQuery("order", "desc"))
+	if direction != "ASC" && direction != "DESC" {
+		return nil, false
+	}
+	query = query.Order("CASE WHEN top = 'Y' THEN 0 ELSE 1 END").
+		Order(column + " " + direction).
+		Order("id " + direction)
+	return query, true
+}
+
+func (h *Press) respondNewsPage(c *gin.Context, query *gorm.DB, pageNum, pageSize int) {
 	var total int64
-	query.Count(&total)
+	if err := query.Count(&total).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
+		return
+	}
 
-	query.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&newsList)
+	var newsList []models.PressNews
+	if err := query.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&newsList).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
+		return
+	}
 	items := make([]gin.H, 0, len(newsList))
 	for _, v := range newsList {
 		items = append(items, buildPressItem(v))
@@ -861,31 +1046,24 @@ func PressCategoryNewsList(c *gin.Context) {
 	respondList(c, "查询成功", items, total)
 }
 
-func PressNewsDetail(c *gin.Context) {
+func (h *Press) PressNewsDetail(c *gin.Context) {
 	id := c.Param("id")
 	var news models.PressNews
-	if err := config.DB.First(&news, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "新闻不存在"})
+	if err := h.requestDB(c).First(&news, id).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "新闻不存在")
 		return
 	}
-	config.DB.Model(&news).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1))
+	h.requestDB(c).Model(&news).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1))
 	news.ViewCount += 1
 	item := buildPressItem(news)
 	item["appType"] = "community"
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功", Data: item})
 }
 
-func PressNewsCreate(c *gin.Context) {
-	var req struct {
-		Title      string `json:"title" binding:"required"`
-		SubTitle   string `json:"subTitle"`
-		Content    string `json:"content" binding:"required"`
-		CategoryId int    `json:"categoryId" binding:"required"`
-		Type       string `json:"type"`
-		ImageUrls  string `json:"imageUrls"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Press) PressNewsCreate(c *gin.Context) {
+	var req PressNewsCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	news := models.PressNews{
@@ -895,31 +1073,29 @@ func PressNewsCreate(c *gin.Context) {
 		CategoryId:  req.CategoryId,
 		Type:        req.Type,
 		ImageUrls:   req.ImageUrls,
+		Top:         req.Top,
+		Hot:         req.Hot,
+		Tags:        req.Tags,
 		Status:      "0",
-		PublishDate: time.Now(),
+		PublishDate: h.now(),
+	}
+	if err := h.requestDB(c).Create(&news).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
 	}
-	config.DB.Create(&news)
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: news.ID})
 }
 
-func PressNewsUpdate(c *gin.Context) {
+func (h *Press) PressNewsUpdate(c *gin.Context) {
 	id := c.Param("id")
 	newsId, err := strconv.Atoi(id)
 	if err != nil || newsId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Title      string `json:"title"`
-		SubTitle   string `json:"subTitle"`
-		Content    string `json:"content"`
-		CategoryId int    `json:"categoryId"`
-		Type       string `json:"type"`
-		ImageUrls  string `json:"imageUrls"`
-		Status     string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req PressNewsUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	updates := map[string]interface{}{}
@@ -944,80 +1120,85 @@ func PressNewsUpdate(c *gin.Context) {
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
-	result := config.DB.Model(&models.PressNews{}).Where("id = ?", newsId).Updates(updates)
+	if req.Top != "" {
+		updates["top"] = req.Top
+	}
+	if req.Hot != "" {
+		updates["hot"] = req.Hot
+	}
+	if req.Tags != "" {
+		updates["tags"] = req.Tags
+	}
+	result := h.requestDB(c).Model(&models.PressNews{}).Where("id = ?", newsId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "新闻不存在"})
+		fail(c, 404, middleware.ClassNotFound, "新闻不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func PressNewsDelete(c *gin.Context) {
+func (h *Press) PressNewsDelete(c *gin.Context) {
 	id := c.Param("id")
 	newsId, err := strconv.Atoi(id)
 	if err != nil || newsId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.PressNews{}, newsId)
+	result := h.requestDB(c).Delete(&models.PressNews{}, newsId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "新闻不存在"})
+		fail(c, 404, middleware.ClassNotFound, "新闻不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func PressLike(c *gin.Context) {
+func (h *Press) PressLike(c *gin.Context) {
 	newsID, err := strconv.Atoi(c.Param("id"))
 	if err != nil || newsID <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	userId := c.GetInt("userId")
 
 	var news models.PressNews
-	if err := config.DB.First(&news, newsID).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "新闻不存在"})
+	if err := h.requestDB(c).First(&news, newsID).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "新闻不存在")
 		return
 	}
 
 	like := models.PressLikeRecord{NewsId: newsID, UserId: userId}
-	tx := config.DB.Where("news_id = ? AND user_id = ?", newsID, userId).FirstOrCreate(&like)
+	tx := h.requestDB(c).Where("news_id = ? AND user_id = ?", newsID, userId).FirstOrCreate(&like)
 	if tx.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "操作失败"})
+		fail(c, 500, middleware.ClassInternal, "操作失败")
 		return
 	}
 	if tx.RowsAffected > 0 {
-		config.DB.Model(&models.PressNews{}).Where("id = ?", newsID).UpdateColumn("like_num", gorm.Expr("like_num + ?", 1))
+		h.requestDB(c).Model(&models.PressNews{}).Where("id = ?", newsID).UpdateColumn("like_num", gorm.Expr("like_num + ?", 1))
 		c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 	} else {
 		c.JSON(http.StatusOK, Response{Code: 200, Msg: "已经点赞过了"})
 	}
 }
 
-func PressComment(c *gin.Context) {
-	var req struct {
-		NewsID   string `json:"newsId" binding:"required"`
-		Content  string `json:"content" binding:"required"`
-		UserName string `json:"userName" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Press) PressComment(c *gin.Context) {
+	var req PressCommentRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	userId := c.GetInt("userId")
 	newsId, err := strconv.Atoi(req.NewsID)
 	if err != nil || newsId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
@@ -1026,28 +1207,31 @@ func PressComment(c *gin.Context) {
 		Content:    req.Content,
 		UserId:     userId,
 		NickName:   req.UserName,
-		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+		CreateTime: h.now().Format("2006-01-02 15:04:05"),
 	}
 	var news models.PressNews
-	if err := config.DB.First(&news, newsId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "新闻不存在"})
+	if err := h.requestDB(c).First(&news, newsId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "新闻不存在")
+		return
+	}
+	if err := h.requestDB(c).Create(&comment).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "提交失败")
 		return
 	}
-	config.DB.Create(&comment)
-	config.DB.Model(&models.PressNews{}).Where("id = ?", newsId).UpdateColumn("comment_num", gorm.Expr("comment_num + ?", 1))
+	h.requestDB(c).Model(&models.PressNews{}).Where("id = ?", newsId).UpdateColumn("comment_num", gorm.Expr("comment_num + ?", 1))
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func CommentList(c *gin.Context) {
+func (h *Press) CommentList(c *gin.Context) {
 	id := c.Param("id")
-	pageNum, pageSize := parsePaging(c)
+	pageNum, pageSize := h.parsePaging(c)
 
 	var comments []models.Comment
 	var total int64
-	config.DB.Model(&models.Comment{}).Where("sid = ?", id).Count(&total)
+	h.requestDB(c).Model(&models.Comment{}).Where("sid = ?", id).Count(&total)
 
-	config.DB.Where("sid = ?", id).Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&comments)
+	h.requestDB(c).Where("sid = ?", id).Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&comments)
 	items := make([]gin.H, 0, len(comments))
 	for _, v := range comments {
 		items = append(items, gin.H{
@@ -1062,48 +1246,69 @@ func CommentList(c *gin.Context) {
 	respondList(c, "获取数据成功", items, total)
 }
 
-func CommentLike(c *gin.Context) {
+func (h *Press) CommentLike(c *gin.Context) {
 	commentID, err := strconv.Atoi(c.Param("id"))
 	if err != nil || commentID <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	userId := c.GetInt("userId")
 
 	var comment models.Comment
-	if err := config.DB.First(&comment, commentID).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "评论不存在"})
+	if err := h.requestDB(c).First(&comment, commentID).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "评论不存在")
 		return
 	}
 
 	like := models.CommentLikeRecord{CommentId: commentID, UserId: userId}
-	tx := config.DB.Where("comment_id = ? AND user_id = ?", commentID, userId).FirstOrCreate(&like)
+	tx := h.requestDB(c).Where("comment_id = ? AND user_id = ?", commentID, userId).FirstOrCreate(&like)
 	if tx.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "操作失败"})
+		fail(c, 500, middleware.ClassInternal, "操作失败")
 		return
 	}
 	if tx.RowsAffected > 0 {
-		config.DB.Model(&models.Comment{}).Where("id = ?", commentID).UpdateColumn("like_num", gorm.Expr("like_num + ?", 1))
+		h.requestDB(c).Model(&models.Comment{}).Where("id = ?", commentID).UpdateColumn("like_num", gorm.Expr("like_num + ?", 1))
 		c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 	} else {
 		c.JSON(http.StatusOK, Response{Code: 200, Msg: "已经点赞过了"})
 	}
 }
 
+// uploadURLPrefix is the URL namespace of uploaded files. It is stored in
+// database rows, so it stays fixed while UploadRoot (the disk location) is
+// configurable.
+const uploadURLPrefix = "/profile/upload/"
+
+// uploadDiskPath maps an upload URL to its file under UploadRoot.
+func (h *Media) uploadDiskPath(urlPath string) string {
+	rel := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(urlPath)), uploadURLPrefix)
+	return filepath.Join(h.cfg.UploadRoot, filepath.FromSlash(rel))
+}
+
+// uploadURLForPath is the inverse of uploadDiskPath for files found by
+// walking UploadRoot.
+func (h *Media) uploadURLForPath(path string) string {
+	rel, err := filepath.Rel(h.cfg.UploadRoot, path)
+	if err != nil {
+		return ""
+	}
+	return uploadURLPrefix + filepath.ToSlash(rel)
+}
+
 func thumbnailURLForImage(urlPath string) string {
 	cleanPath := filepath.ToSlash(filepath.Clean(urlPath))
-	if !strings.HasPrefix(cleanPath, "/profile/upload/") {
+	if !strings.HasPrefix(cleanPath, uploadURLPrefix) {
 		return ""
 	}
-	if strings.HasPrefix(cleanPath, "/profile/upload/file/") {
+	if strings.HasPrefix(cleanPath, uploadURLPrefix+"file/") {
 		return ""
 	}
-	if strings.HasPrefix(cleanPath, "/profile/upload/thumb/") {
+	if strings.HasPrefix(cleanPath, uploadURLPrefix+"thumb/") {
 		return cleanPath
 	}
-	rel := strings.TrimPrefix(cleanPath, "/profile/upload/")
+	rel := strings.TrimPrefix(cleanPath, uploadURLPrefix)
 	relNoExt := strings.TrimSuffix(rel, filepath.Ext(rel))
-	return "/profile/upload/thumb/" + relNoExt + ".jpg"
+	return uploadURLPrefix + "thumb/" + relNoExt + ".jpg"
 }
 
 func isImageExt(ext string) bool {
@@ -1157,47 +1362,58 @@ func encodeJPEGUnderLimit(img image.Image, maxBytes int) ([]byte, error) {
 	return best, nil
 }
 
-func ensureThumbnail(urlPath string) string {
+func (h *Media) ensureThumbnail(urlPath string) string {
+	thumbURL, result := h.buildThumbnail(urlPath, false)
+	if result != "" {
+		metrics.ObserveThumbnail(result)
+	}
+	return thumbURL
+}
+
+// buildThumbnail returns the thumbnail URL and a metrics.Thumbnail* result,
+// or an empty result when urlPath has no thumbnail (not an upload, missing
+// source). force regenerates even an up-to-date thumbnail.
+func (h *Media) buildThumbnail(urlPath string, force bool) (string, string) {
 	thumbURL := thumbnailURLForImage(urlPath)
 	if thumbURL == "" {
-		return urlPath
+		return urlPath, ""
 	}
 
-	sourcePath := "." + urlPath
-	thumbPath := "." + thumbURL
+	sourcePath := h.uploadDiskPath(urlPath)
+	thumbPath := h.uploadDiskPath(thumbURL)
 
 	sourceInfo, err := os.Stat(sourcePath)
 	if err != nil || sourceInfo.IsDir() {
-		return urlPath
+		return urlPath, ""
 	}
 
-	if thumbInfo, err := os.Stat(thumbPath); err == nil && !thumbInfo.IsDir() && !thumbInfo.ModTime().Before(sourceInfo.ModTime()) {
-		return thumbURL
+	if thumbInfo, err := os.Stat(thumbPath); !force && err == nil && !thumbInfo.IsDir() && !thumbInfo.ModTime().Before(sourceInfo.ModTime()) {
+		return thumbURL, metrics.ThumbnailHit
 	}
 
 	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
-		return urlPath
+		return urlPath, metrics.ThumbnailFailure
 	}
 
 	sourceFile, err := os.Open(sourcePath)
 	if err != nil {
-		return urlPath
+		return urlPath, metrics.ThumbnailFailure
 	}
 	defer sourceFile.Close()
 
 	img, _, err := image.Decode(sourceFile)
 	if err != nil {
-		return urlPath
+		return urlPath, metrics.ThumbnailFailure
 	}
 
 	bounds := img.Bounds()
 	srcW, srcH := bounds.Dx(), bounds.Dy()
 	if srcW <= 0 || srcH <= 0 {
-		return urlPath
+		return urlPath, metrics.ThumbnailFailure
 	}
 
-	const maxSide = 140
-	const targetBytes = 28 * 1024
+	maxSide := h.cfg.ThumbnailMaxSide
+	targetBytes := h.cfg.ThumbnailMaxBytes
 	targetW, targetH := srcW, srcH
 	if srcW > maxSide || srcH > maxSide {
 		if srcW >= srcH {
@@ -1218,56 +1434,116 @@ func ensureThumbnail(urlPath string) string {
 	thumbImage := flattenToWhite(resizeImageNearest(img, targetW, targetH))
 	thumbData, err := encodeJPEGUnderLimit(thumbImage, targetBytes)
 	if err != nil || len(thumbData) == 0 {
-		return urlPath
+		return urlPath, metrics.ThumbnailFailure
 	}
 
-	if err := os.WriteFile(thumbPath, thumbData, 0644); err != nil {
-		_ = os.Remove(thumbPath)
-		return urlPath
+	if err := writeFileAtomic(thumbPath, thumbData, 0644); err != nil {
+		return urlPath, metrics.ThumbnailFailure
 	}
 
-	return thumbURL
+	return thumbURL, metrics.ThumbnailMiss
 }
 
-func StartThumbnailWarmup() {
+// writeFileAtomic writes to a temp file in the same directory and renames it
+// into place, so an interrupted write never leaves a truncated file at path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// StartThumbnailWarmup generates missing thumbnails in the background. The
+// returned channel is closed once the walk finishes or ctx is cancelled.
+func (h *Media) StartThumbnailWarmup(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
-		uploadDir := "./profile/upload"
-		_ = filepath.Walk(uploadDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if info.IsDir() {
-				if filepath.ToSlash(path) == filepath.ToSlash(filepath.Join(uploadDir, "thumb")) {
-					return filepath.SkipDir
-				}
-				if filepath.ToSlash(path) == filepath.ToSlash(filepath.Join(uploadDir, "file")) {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+		defer close(done)
+		h.walkUploadImages(ctx, func(urlPath string) {
+			_ = h.ensureThumbnail(urlPath)
+		})
+	}()
+	return done
+}
+
+type ThumbnailStats struct {
+	Generated int
+	Skipped   int
+	Failed    int
+}
+
+// RebuildThumbnails walks every uploaded image synchronously. Without force
+// only missing or stale thumbnails are generated.
+func (h *Media) RebuildThumbnails(ctx context.Context, force bool) (ThumbnailStats, error) {
+	var stats ThumbnailStats
+	h.walkUploadImages(ctx, func(urlPath string) {
+		_, result := h.buildThumbnail(urlPath, force)
+		switch result {
+		case metrics.ThumbnailMiss:
+			stats.Generated++
+		case metrics.ThumbnailFailure:
+			stats.Failed++
+		default:
+			stats.Skipped++
+		}
+	})
+	return stats, ctx.Err()
+}
 
-			ext := strings.ToLower(filepath.Ext(path))
-			if !isImageExt(ext) {
-				return nil
+// walkUploadImages calls fn with the URL path of each image under the upload
+// root, skipping generated thumbnails and non-image files.
+func (h *Media) walkUploadImages(ctx context.Context, fn func(urlPath string)) {
+	uploadDir := h.cfg.UploadRoot
+	_ = filepath.Walk(uploadDir, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if filepath.ToSlash(path) == filepath.ToSlash(filepath.Join(uploadDir, "thumb")) {
+				return filepath.SkipDir
 			}
-
-			relPath := strings.TrimPrefix(filepath.ToSlash(path), ".")
-			if !strings.HasPrefix(relPath, "/") {
-				relPath = "/" + relPath
+			if filepath.ToSlash(path) == filepath.ToSlash(filepath.Join(uploadDir, "file")) {
+				return filepath.SkipDir
 			}
-			_ = ensureThumbnail(relPath)
 			return nil
-		})
-	}()
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if !isImageExt(ext) {
+			return nil
+		}
+
+		fn(h.uploadURLForPath(path))
+		return nil
+	})
 }
 
-func ImageList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
-	if pageSize > 60 {
-		pageSize = 60
-	}
+func (h *Media) ImageList(c *gin.Context) {
+	pageNum, pageSize := h.parseMediaPaging(c)
 
-	uploadDir := "./profile/upload"
+	uploadDir := h.cfg.UploadRoot
 	type imageMeta struct {
 		name    string
 		url     string
@@ -1292,11 +1568,7 @@ func ImageList(c *gin.Context) {
 		}
 		ext := strings.ToLower(filepath.Ext(path))
 		if isImageExt(ext) {
-			relPath := strings.TrimPrefix(filepath.ToSlash(path), ".")
-			if !strings.HasPrefix(relPath, "/") {
-				relPath = "/" + relPath
-			}
-			metas = append(metas, imageMeta{name: info.Name(), url: relPath, size: info.Size(), modTime: info.ModTime()})
+			metas = append(metas, imageMeta{name: info.Name(), url: h.uploadURLForPath(path), size: info.Size(), modTime: info.ModTime()})
 		}
 		return nil
 	})
@@ -1323,10 +1595,10 @@ func ImageList(c *gin.Context) {
 		thumbURL := thumbnailURLForImage(m.url)
 		if thumbURL == "" {
 			thumbURL = m.url
-		} else if _, err := os.Stat("." + thumbURL); err == nil {
+		} else if _, err := os.Stat(h.uploadDiskPath(thumbURL)); err == nil {
 			// use existing thumbnail
 		} else {
-			thumbURL = ensureThumbnail(m.url)
+			thumbURL = h.ensureThumbnail(m.url)
 		}
 
 		images = append(images, gin.H{
@@ -1341,39 +1613,39 @@ func ImageList(c *gin.Context) {
 	respondList(c, "获取成功", images, int64(total))
 }
 
-func ImageDelete(c *gin.Context) {
+func (h *Media) ImageDelete(c *gin.Context) {
 	urlPath := c.Query("url")
-	cleanedURL, targetPath, err := resolveDeletePath(urlPath, "image")
+	cleanedURL, targetPath, err := h.resolveDeletePath(urlPath, "image")
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	info, err := os.Stat(targetPath)
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "文件不存在"})
+		fail(c, 404, middleware.ClassNotFound, "文件不存在")
 		return
 	}
 	if info.IsDir() {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if err := os.Remove(targetPath); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 
 	thumbURL := thumbnailURLForImage(cleanedURL)
 	if thumbURL != "" {
-		_ = os.Remove("." + thumbURL)
+		_ = os.Remove(h.uploadDiskPath(thumbURL))
 	}
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func Upload(c *gin.Context) {
+func (h *Media) Upload(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "上传文件失败"})
+		fail(c, 500, middleware.ClassInternal, "上传文件失败")
 		return
 	}
 
@@ -1383,19 +1655,21 @@ func Upload(c *gin.Context) {
 	if !isImageExt(ext) {
 		baseDir = "file"
 	}
-	filename := "/profile/upload/" + baseDir + "/" + time.Now().Format("20060102150405") + "_" + name
-	targetPath := "." + filename
+	filename := uploadURLPrefix + baseDir + "/" + h.now().Format("20060102150405") + "_" + name
+	targetPath := h.uploadDiskPath(filename)
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "上传文件失败"})
+		fail(c, 500, middleware.ClassInternal, "上传文件失败")
 		return
 	}
 	if err := c.SaveUploadedFile(file, targetPath); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "上传文件失败"})
+		fail(c, 500, middleware.ClassInternal, "上传文件失败")
 		return
 	}
 
+	metrics.ObserveUpload(baseDir, file.Size)
+
 	if baseDir == "image" {
-		_ = ensureThumbnail(filename)
+		_ = h.ensureThumbnail(filename)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -1406,13 +1680,10 @@ func Upload(c *gin.Context) {
 	})
 }
 
-func FileList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
-	if pageSize > 60 {
-		pageSize = 60
-	}
+func (h *Media) FileList(c *gin.Context) {
+	pageNum, pageSize := h.parseMediaPaging(c)
 
-	uploadDir := "./profile/upload/file"
+	uploadDir := filepath.Join(h.cfg.UploadRoot, "file")
 	type fileMeta struct {
 		name    string
 		url     string
@@ -1426,11 +1697,7 @@ func FileList(c *gin.Context) {
 		if err != nil || info.IsDir() {
 			return nil
 		}
-		relPath := strings.TrimPrefix(filepath.ToSlash(path), ".")
-		if !strings.HasPrefix(relPath, "/") {
-			relPath = "/" + relPath
-		}
-		metas = append(metas, fileMeta{name: info.Name(), url: relPath, size: info.Size(), modTime: info.ModTime()})
+		metas = append(metas, fileMeta{name: info.Name(), url: h.uploadURLForPath(path), size: info.Size(), modTime: info.ModTime()})
 		return nil
 	})
 
@@ -1464,47 +1731,46 @@ func FileList(c *gin.Context) {
 	respondList(c, "获取成功", files, int64(total))
 }
 
-func FileDelete(c *gin.Context) {
+func (h *Media) FileDelete(c *gin.Context) {
 	urlPath := c.Query("url")
-	_, targetPath, err := resolveDeletePath(urlPath, "file")
+	_, targetPath, err := h.resolveDeletePath(urlPath, "file")
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	info, err := os.Stat(targetPath)
 	if err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "文件不存在"})
+		fail(c, 404, middleware.ClassNotFound, "文件不存在")
 		return
 	}
 	if info.IsDir() {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if err := os.Remove(targetPath); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func resolveDeletePath(urlPath, kind string) (string, string, error) {
+func (h *Media) resolveDeletePath(urlPath, kind string) (string, string, error) {
 	trimmed := strings.TrimSpace(urlPath)
 	if trimmed == "" {
 		return "", "", fmt.Errorf("empty path")
 	}
 
 	cleanedURL := filepath.ToSlash(filepath.Clean("/" + strings.TrimPrefix(trimmed, "/")))
-	basePrefix := "/profile/upload/" + kind + "/"
+	basePrefix := uploadURLPrefix + kind + "/"
 	if !strings.HasPrefix(cleanedURL, basePrefix) {
 		return "", "", fmt.Errorf("invalid path")
 	}
 
-	baseDir := "." + strings.TrimSuffix(basePrefix, "/")
-	baseAbs, err := filepath.Abs(baseDir)
+	baseAbs, err := filepath.Abs(filepath.Join(h.cfg.UploadRoot, kind))
 	if err != nil {
 		return "", "", err
 	}
-	targetAbs, err := filepath.Abs("." + cleanedURL)
+	targetAbs, err := filepath.Abs(h.uploadDiskPath(cleanedURL))
 	if err != nil {
 		return "", "", err
 	}
@@ -1515,12 +1781,12 @@ func resolveDeletePath(urlPath, kind string) (string, string, error) {
 	return cleanedURL, targetAbs, nil
 }
 
-func NoticeList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Press) NoticeList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 	noticeStatus := c.Query("noticeStatus")
 
 	var notices []models.Notice
-	query := config.DB.Model(&models.Notice{})
+	query := h.requestDB(c).Model(&models.Notice{})
 	if noticeStatus != "" {
 		query = query.Where("notice_status = ?", noticeStatus)
 	}
@@ -1536,25 +1802,20 @@ func NoticeList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func NoticeDetail(c *gin.Context) {
+func (h *Press) NoticeDetail(c *gin.Context) {
 	id := c.Param("id")
 	var notice models.Notice
-	if err := config.DB.First(&notice, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "公告不存在"})
+	if err := h.requestDB(c).First(&notice, id).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "公告不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功", Data: buildNoticeItem(notice)})
 }
 
-func NoticeCreate(c *gin.Context) {
-	var req struct {
-		Title         string `json:"title" binding:"required"`
-		NoticeContent string `json:"noticeContent" binding:"required"`
-		NoticeStatus  string `json:"noticeStatus"`
-		CreateBy      string `json:"createBy"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Press) NoticeCreate(c *gin.Context) {
+	var req NoticeCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	notice := models.Notice{
@@ -1562,27 +1823,25 @@ func NoticeCreate(c *gin.Context) {
 		NoticeContent: req.NoticeContent,
 		NoticeStatus:  req.NoticeStatus,
 		CreateBy:      req.CreateBy,
-		PublishDate:   time.Now(),
+		PublishDate:   h.now(),
+	}
+	if err := h.requestDB(c).Create(&notice).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
 	}
-	config.DB.Create(&notice)
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: notice.ID})
 }
 
-func NoticeUpdate(c *gin.Context) {
+func (h *Press) NoticeUpdate(c *gin.Context) {
 	id := c.Param("id")
 	noticeId, err := strconv.Atoi(id)
 	if err != nil || noticeId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Title         string `json:"title"`
-		NoticeContent string `json:"noticeContent"`
-		NoticeStatus  string `json:"noticeStatus"`
-		CreateBy      string `json:"createBy"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req NoticeUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	updates := map[string]interface{}{}
@@ -1598,63 +1857,63 @@ func NoticeUpdate(c *gin.Context) {
 	if req.CreateBy != "" {
 		updates["create_by"] = req.CreateBy
 	}
-	result := config.DB.Model(&models.Notice{}).Where("id = ?", noticeId).Updates(updates)
+	result := h.requestDB(c).Model(&models.Notice{}).Where("id = ?", noticeId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "公告不存在"})
+		fail(c, 404, middleware.ClassNotFound, "公告不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func NoticeDelete(c *gin.Context) {
+func (h *Press) NoticeDelete(c *gin.Context) {
 	id := c.Param("id")
 	noticeId, err := strconv.Atoi(id)
 	if err != nil || noticeId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.Notice{}, noticeId)
+	result := h.requestDB(c).Delete(&models.Notice{}, noticeId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "公告不存在"})
+		fail(c, 404, middleware.ClassNotFound, "公告不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func ReadNotice(c *gin.Context) {
+func (h *Press) ReadNotice(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Model(&models.Notice{}).Where("id = ?", id).Update("notice_status", "1")
+	result := h.requestDB(c).Model(&models.Notice{}).Where("id = ?", id).Update("notice_status", "1")
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "操作失败"})
+		fail(c, 500, middleware.ClassInternal, "操作失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "公告不存在"})
+		fail(c, 404, middleware.ClassNotFound, "公告不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func FriendlyNeighborList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Activity) FriendlyNeighborList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 
 	var neighbors []models.FriendlyNeighbor
 	var total int64
-	config.DB.Model(&models.FriendlyNeighbor{}).Count(&total)
+	h.requestDB(c).Model(&models.FriendlyNeighbor{}).Count(&total)
 
-	config.DB.Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("create_time DESC").Find(&neighbors)
+	h.requestDB(c).Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("create_time DESC").Find(&neighbors)
 	items := make([]gin.H, 0, len(neighbors))
 	for _, v := range neighbors {
 		items = append(items, buildNeighborItem(v))
@@ -1662,13 +1921,10 @@ func FriendlyNeighborList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func FriendlyNeighborAddComment(c *gin.Context) {
-	var req struct {
-		NeighborhoodID int    `json:"neighborhoodId" binding:"required"`
-		Content        string `json:"content" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Activity) FriendlyNeighborAddComment(c *gin.Context) {
+	var req FriendlyNeighborAddCommentRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
@@ -1683,25 +1939,28 @@ func FriendlyNeighborAddComment(c *gin.Context) {
 		UserId:     userId,
 		NickName:   nickName,
 		Content:    req.Content,
-		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+		CreateTime: h.now().Format("2006-01-02 15:04:05"),
+	}
+	if err := h.requestDB(c).Create(&comment).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "提交失败")
+		return
 	}
-	config.DB.Create(&comment)
 
-	config.DB.Model(&models.FriendlyNeighbor{}).Where("id = ?", req.NeighborhoodID).UpdateColumn("comment_num", gorm.Expr("comment_num + ?", 1))
+	h.requestDB(c).Model(&models.FriendlyNeighbor{}).Where("id = ?", req.NeighborhoodID).UpdateColumn("comment_num", gorm.Expr("comment_num + ?", 1))
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func FriendlyNeighborDetail(c *gin.Context) {
+func (h *Activity) FriendlyNeighborDetail(c *gin.Context) {
 	id := c.Param("id")
 	var neighbor models.FriendlyNeighbor
-	if err := config.DB.First(&neighbor, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "记录不存在"})
+	if err := h.requestDB(c).First(&neighbor, id).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "记录不存在")
 		return
 	}
 
 	var comments []models.FNComment
-	config.DB.Where("neighbor_id = ?", id).Find(&comments)
+	h.requestDB(c).Where("neighbor_id = ?", id).Find(&comments)
 
 	commentItems := make([]gin.H, 0, len(comments))
 	for _, v := range comments {
@@ -1721,45 +1980,40 @@ func FriendlyNeighborDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功", Data: item})
 }
 
-func FriendlyNeighborCreate(c *gin.Context) {
-	var req struct {
-		Content    string `json:"content" binding:"required"`
-		ImgUrl     string `json:"imgUrl"`
-		UserId     int    `json:"userId"`
-		NickName   string `json:"nickName"`
-		UserImgUrl string `json:"userImgUrl"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Activity) FriendlyNeighborCreate(c *gin.Context) {
+	var req FriendlyNeighborCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	neighbor := models.FriendlyNeighbor{
 		Content:    req.Content,
 		ImgUrl:     req.ImgUrl,
-		UserId:     req.UserId,
+		UserId:     c.GetInt("userId"),
 		NickName:   req.NickName,
 		UserImgUrl: req.UserImgUrl,
-		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+		CreateTime: h.now().Format("2006-01-02 15:04:05"),
+	}
+	if err := h.requestDB(c).Create(&neighbor).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
 	}
-	config.DB.Create(&neighbor)
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: neighbor.ID})
 }
 
-func FriendlyNeighborUpdate(c *gin.Context) {
+func (h *Activity) FriendlyNeighborUpdate(c *gin.Context) {
 	id := c.Param("id")
 	neighborId, err := strconv.Atoi(id)
 	if err != nil || neighborId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Content    string `json:"content"`
-		NickName   string `json:"nickName"`
-		ImgUrl     string `json:"imgUrl"`
-		UserImgUrl string `json:"userImgUrl"`
+	var req FriendlyNeighborUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	if !h.canModifyNeighbor(c, neighborId) {
 		return
 	}
 	updates := map[string]interface{}{}
@@ -1775,46 +2029,64 @@ func FriendlyNeighborUpdate(c *gin.Context) {
 	if req.UserImgUrl != "" {
 		updates["user_img_url"] = req.UserImgUrl
 	}
-	result := config.DB.Model(&models.FriendlyNeighbor{}).Where("id = ?", neighborId).Updates(updates)
+	result := h.requestDB(c).Model(&models.FriendlyNeighbor{}).Where("id = ?", neighborId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "帖子不存在"})
+		fail(c, 404, middleware.ClassNotFound, "帖子不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func FriendlyNeighborDelete(c *gin.Context) {
+func (h *Activity) FriendlyNeighborDelete(c *gin.Context) {
 	id := c.Param("id")
 	neighborId, err := strconv.Atoi(id)
 	if err != nil || neighborId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.FriendlyNeighbor{}, neighborId)
+	if !h.canModifyNeighbor(c, neighborId) {
+		return
+	}
+	result := h.requestDB(c).Delete(&models.FriendlyNeighbor{}, neighborId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "帖子不存在"})
+		fail(c, 404, middleware.ClassNotFound, "帖子不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func ActivityTopList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+// canModifyNeighbor lets the author and content managers change a post, and
+// answers the request itself otherwise.
+func (h *Activity) canModifyNeighbor(c *gin.Context, neighborId int) bool {
+	var neighbor models.FriendlyNeighbor
+	if err := h.requestDB(c).Select("id", "user_id").First(&neighbor, neighborId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "帖子不存在")
+		return false
+	}
+	if neighbor.UserId != c.GetInt("userId") && !middleware.HasPermission(c.GetString("role"), middleware.PermContentManage) {
+		fail(c, 403, middleware.ClassForbidden, "无权限")
+		return false
+	}
+	return true
+}
+
+func (h *Activity) ActivityTopList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 
 	var activities []models.Activity
 	var total int64
-	query := config.DB.Model(&models.Activity{}).Where("is_top = ?", "1")
+	query := h.requestDB(c).Model(&models.Activity{}).Where("is_top = ?", "1")
 	query.Count(&total)
 	if total == 0 {
-		query = config.DB.Model(&models.Activity{})
+		query = h.requestDB(c).Model(&models.Activity{})
 		query.Count(&total)
 	}
 
@@ -1826,14 +2098,14 @@ func ActivityTopList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func ActivityList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Activity) ActivityList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 
 	var activities []models.Activity
 	var total int64
-	config.DB.Model(&models.Activity{}).Count(&total)
+	h.requestDB(c).Model(&models.Activity{}).Count(&total)
 
-	config.DB.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&activities)
+	h.requestDB(c).Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&activities)
 	items := make([]gin.H, 0, len(activities))
 	for _, v := range activities {
 		items = append(items, buildActivityItem(v))
@@ -1841,18 +2113,16 @@ func ActivityList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func ActivitySearch(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
-	var req struct {
-		Words string `json:"words" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Activity) ActivitySearch(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
+	var req ActivitySearchRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	var activities []models.Activity
-	query := config.DB.Model(&models.Activity{})
+	query := h.requestDB(c).Model(&models.Activity{})
 	if req.Words != "" {
 		query = query.Where("title LIKE ? OR content LIKE ?", "%"+req.Words+"%", "%"+req.Words+"%")
 	}
@@ -1868,22 +2138,22 @@ func ActivitySearch(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func ActivityDetail(c *gin.Context) {
+func (h *Activity) ActivityDetail(c *gin.Context) {
 	id := c.Param("id")
 	var activity models.Activity
-	if err := config.DB.First(&activity, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "活动不存在"})
+	if err := h.requestDB(c).First(&activity, id).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "活动不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功", Data: buildActivityItem(activity)})
 }
 
-func ActivityCategoryList(c *gin.Context) {
+func (h *Activity) ActivityCategoryList(c *gin.Context) {
 	id := c.Param("id")
-	pageNum, pageSize := parsePaging(c)
+	pageNum, pageSize := h.parsePaging(c)
 
 	var activities []models.Activity
-	query := config.DB.Model(&models.Activity{}).Where("category_id = ?", id)
+	query := h.requestDB(c).Model(&models.Activity{}).Where("category_id = ?", id)
 
 	var total int64
 	query.Count(&total)
@@ -1896,21 +2166,10 @@ func ActivityCategoryList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func ActivityCreate(c *gin.Context) {
-	var req struct {
-		Title      string    `json:"title" binding:"required"`
-		Content    string    `json:"content" binding:"required"`
-		PicPath    string    `json:"picPath"`
-		CategoryId int       `json:"categoryId" binding:"required"`
-		StartDate  time.Time `json:"startDate"`
-		EndDate    time.Time `json:"endDate"`
-		Address    string    `json:"address"`
-		TotalCount int       `json:"totalCount"`
-		IsTop      string    `json:"isTop"`
-		CreateBy   string    `json:"createBy"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Activity) ActivityCreate(c *gin.Context) {
+	var req ActivityCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	activity := models.Activity{
@@ -1927,32 +2186,23 @@ func ActivityCreate(c *gin.Context) {
 		Status:       "0",
 		CreateBy:     req.CreateBy,
 	}
-	config.DB.Create(&activity)
+	if err := h.requestDB(c).Create(&activity).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
+		return
+	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功", Data: activity.ID})
 }
 
-func ActivityUpdate(c *gin.Context) {
+func (h *Activity) ActivityUpdate(c *gin.Context) {
 	id := c.Param("id")
 	activityId, err := strconv.Atoi(id)
 	if err != nil || activityId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	var req struct {
-		Title      string    `json:"title"`
-		Content    string    `json:"content"`
-		PicPath    string    `json:"picPath"`
-		CategoryId int       `json:"categoryId"`
-		StartDate  time.Time `json:"startDate"`
-		EndDate    time.Time `json:"endDate"`
-		Address    string    `json:"address"`
-		TotalCount int       `json:"totalCount"`
-		IsTop      string    `json:"isTop"`
-		Status     string    `json:"status"`
-		CreateBy   string    `json:"createBy"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req ActivityUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	updates := map[string]interface{}{}
@@ -1989,44 +2239,48 @@ func ActivityUpdate(c *gin.Context) {
 	if req.CreateBy != "" {
 		updates["create_by"] = req.CreateBy
 	}
-	result := config.DB.Model(&models.Activity{}).Where("id = ?", activityId).Updates(updates)
+	result := h.requestDB(c).Model(&models.Activity{}).Where("id = ?", activityId).Updates(updates)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "活动不存在"})
+		fail(c, 404, middleware.ClassNotFound, "活动不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func ActivityDelete(c *gin.Context) {
+func (h *Activity) ActivityDelete(c *gin.Context) {
 	id := c.Param("id")
 	activityId, err := strconv.Atoi(id)
 	if err != nil || activityId <= 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	result := config.DB.Delete(&models.Activity{}, activityId)
+	result := h.requestDB(c).Delete(&models.Activity{}, activityId)
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "活动不存在"})
+		fail(c, 404, middleware.ClassNotFound, "活动不存在")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func RegistrationList(c *gin.Context) {
-	pageNum, pageSize := parsePaging(c)
+func (h *Activity) RegistrationList(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
 	activityID := c.Query("activityId")
 	userID := c.Query("userId")
+	// Registrations carry phone numbers, so residents only see their own.
+	if !middleware.HasPermission(c.GetString("role"), middleware.PermContentManage) {
+		userID = strconv.Itoa(c.GetInt("userId"))
+	}
 
 	var registrations []models.Registration
-	query := config.DB.Model(&models.Registration{})
+	query := h.requestDB(c).Model(&models.Registration{})
 	if activityID != "" {
 		query = query.Where("activity_id = ?", activityID)
 	}
@@ -2058,12 +2312,10 @@ func RegistrationList(c *gin.Context) {
 	respondList(c, "请求成功", items, total)
 }
 
-func Registration(c *gin.Context) {
-	var req struct {
-		ActivityId int `json:"activityId" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Activity) Registration(c *gin.Context) {
+	var req RegistrationRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
@@ -2072,19 +2324,19 @@ func Registration(c *gin.Context) {
 	nickName := c.GetString("nickName")
 
 	var count int64
-	config.DB.Model(&models.Registration{}).Where("user_id = ? AND activity_id = ?", userId, req.ActivityId).Count(&count)
+	h.requestDB(c).Model(&models.Registration{}).Where("user_id = ? AND activity_id = ?", userId, req.ActivityId).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "已报名"})
+		fail(c, 500, middleware.ClassConflict, "已报名")
 		return
 	}
 
 	var activity models.Activity
-	if err := config.DB.First(&activity, req.ActivityId).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "活动不存在"})
+	if err := h.requestDB(c).First(&activity, req.ActivityId).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "活动不存在")
 		return
 	}
 	if activity.TotalCount > 0 && activity.CurrentCount >= activity.TotalCount {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "活动报名人数已满"})
+		fail(c, 500, middleware.ClassConflict, "活动报名人数已满")
 		return
 	}
 
@@ -2095,65 +2347,65 @@ func Registration(c *gin.Context) {
 		Phone:      c.GetString("phone"),
 		ActivityId: req.ActivityId,
 		Status:     "0",
-		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+		CreateTime: h.now().Format("2006-01-02 15:04:05"),
+	}
+	if err := h.requestDB(c).Create(&registration).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "提交失败")
+		return
 	}
-	config.DB.Create(&registration)
 
-	config.DB.Model(&models.Activity{}).Where("id = ?", req.ActivityId).UpdateColumn("current_count", gorm.Expr("current_count + ?", 1))
+	h.requestDB(c).Model(&models.Activity{}).Where("id = ?", req.ActivityId).UpdateColumn("current_count", gorm.Expr("current_count + ?", 1))
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func Checkin(c *gin.Context) {
+func (h *Activity) Checkin(c *gin.Context) {
 	activityId := c.Param("id")
 	userId := c.GetInt("userId")
-	result := config.DB.Model(&models.Registration{}).Where("activity_id = ? AND user_id = ?", activityId, userId).Update("checkin_status", "1")
+	result := h.requestDB(c).Model(&models.Registration{}).Where("activity_id = ? AND user_id = ?", activityId, userId).Update("checkin_status", "1")
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "操作失败"})
+		fail(c, 500, middleware.ClassInternal, "操作失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "未找到报名记录"})
+		fail(c, 500, middleware.ClassNotFound, "未找到报名记录")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func RegistrationComment(c *gin.Context) {
+func (h *Activity) RegistrationComment(c *gin.Context) {
 	activityId := c.Param("id")
 	userId := c.GetInt("userId")
-	var req struct {
-		Evaluate string `json:"evaluate" binding:"required"`
-		Star     int    `json:"star" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req RegistrationCommentRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Star < 1 || req.Star > 5 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "评分参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "评分参数错误")
 		return
 	}
 
-	result := config.DB.Model(&models.Registration{}).Where("activity_id = ? AND user_id = ?", activityId, userId).Updates(map[string]interface{}{
+	result := h.requestDB(c).Model(&models.Registration{}).Where("activity_id = ? AND user_id = ?", activityId, userId).Updates(map[string]interface{}{
 		"comment": req.Evaluate,
 		"star":    req.Star,
 	})
 	if result.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "操作失败"})
+		fail(c, 500, middleware.ClassInternal, "操作失败")
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "未找到报名记录"})
+		fail(c, 500, middleware.ClassNotFound, "未找到报名记录")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "操作成功"})
 }
 
-func CommonDataCard(c *gin.Context) {
+func (h *Green) CommonDataCard(c *gin.Context) {
 	var cards []models.GreenDataCard
-	if err := config.DB.Order("sort asc, id asc").Find(&cards).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+	if err := h.requestDB(c).Order("sort asc, id asc").Find(&cards).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
 	items := make([]gin.H, 0, len(cards))
@@ -2170,23 +2422,23 @@ func CommonDataCard(c *gin.Context) {
 	respondList(c, "请求成功", items, int64(len(items)))
 }
 
-func QuestionQuestionList(c *gin.Context) {
+func (h *Green) QuestionQuestionList(c *gin.Context) {
 	questionType := c.Param("id")
 	level := c.Param("level")
 	if questionType != "1" && questionType != "4" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "题型参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "题型参数错误")
 		return
 	}
 	if level != "1" && level != "2" && level != "3" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "难度参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "难度参数错误")
 		return
 	}
 
 	var total int64
-	query := config.DB.Model(&models.GreenQuestion{}).
+	query := h.requestDB(c).Model(&models.GreenQuestion{}).
 		Where("question_type = ? AND level = ? AND status = ?", questionType, level, "0")
 	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
 
@@ -2195,11 +2447,31 @@ func QuestionQuestionList(c *gin.Context) {
 		return
 	}
 
-	var list []models.GreenQuestion
-	if err := query.Order("RANDOM()").Limit(5).Find(&list).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+	// Pick the random sample in Go; RANDOM()/RAND() differ between databases.
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
+		return
+	}
+	mrand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	if len(ids) > 5 {
+		ids = ids[:5]
+	}
+	var found []models.GreenQuestion
+	if err := h.requestDB(c).Where("id IN ?", ids).Find(&found).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
+	byID := make(map[uint]models.GreenQuestion, len(found))
+	for _, q := range found {
+		byID[q.ID] = q
+	}
+	list := make([]models.GreenQuestion, 0, len(ids))
+	for _, id := range ids {
+		if q, ok := byID[id]; ok {
+			list = append(list, q)
+		}
+	}
 
 	result := make([]gin.H, 0, len(list))
 	for _, q := range list {
@@ -2221,25 +2493,19 @@ func QuestionQuestionList(c *gin.Context) {
 	respondList(c, "请求成功", result, total)
 }
 
-func QuestionSavePaper(c *gin.Context) {
-	var req struct {
-		Score  interface{} `json:"score"`
-		Answer []struct {
-			Qid    int    `json:"qid"`
-			Answer string `json:"answer"`
-		} `json:"answer"`
-	}
+func (h *Green) QuestionSavePaper(c *gin.Context) {
+	var req QuestionSavePaperRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Score == nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "分数不能为空"})
+		fail(c, 500, middleware.ClassInvalidParams, "分数不能为空")
 		return
 	}
 	if len(req.Answer) == 0 {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "答案不能为空"})
+		fail(c, 500, middleware.ClassInvalidParams, "答案不能为空")
 		return
 	}
 
@@ -2250,27 +2516,27 @@ func QuestionSavePaper(c *gin.Context) {
 		RawInput: string(rawAnswers),
 	}
 
-	tx := config.DB.Begin()
+	tx := h.requestDB(c).Begin()
 	if tx.Error != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "提交失败"})
+		fail(c, 500, middleware.ClassInternal, "提交失败")
 		return
 	}
 	if err := tx.Create(&paper).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "提交失败"})
+		fail(c, 500, middleware.ClassInternal, "提交失败")
 		return
 	}
 
 	for _, item := range req.Answer {
 		if item.Qid <= 0 || strings.TrimSpace(item.Answer) == "" {
 			tx.Rollback()
-			c.JSON(http.StatusOK, Response{Code: 500, Msg: "答案格式错误"})
+			fail(c, 500, middleware.ClassInvalidParams, "答案格式错误")
 			return
 		}
 		var question models.GreenQuestion
 		if err := tx.First(&question, item.Qid).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusOK, Response{Code: 500, Msg: "题目不存在"})
+			fail(c, 500, middleware.ClassNotFound, "题目不存在")
 			return
 		}
 		answer := models.GreenPaperAnswer{
@@ -2282,38 +2548,38 @@ func QuestionSavePaper(c *gin.Context) {
 		}
 		if err := tx.Create(&answer).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusOK, Response{Code: 500, Msg: "提交失败"})
+			fail(c, 500, middleware.ClassInternal, "提交失败")
 			return
 		}
 	}
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "提交失败"})
+		fail(c, 500, middleware.ClassInternal, "提交失败")
 		return
 	}
 
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功"})
 }
 
-func DataList1(c *gin.Context) {
-	listGreenData(c, "list_1")
+func (h *Green) DataList1(c *gin.Context) {
+	h.listGreenData(c, "list_1")
 }
 
-func DataList2(c *gin.Context) {
-	listGreenData(c, "list_2")
+func (h *Green) DataList2(c *gin.Context) {
+	h.listGreenData(c, "list_2")
 }
 
-func DataList3(c *gin.Context) {
-	listGreenData(c, "list_3")
+func (h *Green) DataList3(c *gin.Context) {
+	h.listGreenData(c, "list_3")
 }
 
-func DataList4(c *gin.Context) {
-	listGreenData(c, "list_4")
+func (h *Green) DataList4(c *gin.Context) {
+	h.listGreenData(c, "list_4")
 }
 
-func listGreenData(c *gin.Context, listKey string) {
+func (h *Green) listGreenData(c *gin.Context, listKey string) {
 	var rows []models.GreenDataSeries
-	if err := config.DB.Where("list_key = ?", listKey).Order("sort asc, id asc").Find(&rows).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+	if err := h.requestDB(c).Where("list_key = ?", listKey).Order("sort asc, id asc").Find(&rows).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
 
@@ -2339,17 +2605,10 @@ func parseGreenDataValue(raw string) interface{} {
 	return raw
 }
 
-func GreenDataCardCreate(c *gin.Context) {
-	var req struct {
-		Icon  string `json:"icon"`
-		Title string `json:"title"`
-		Num   string `json:"num"`
-		Unit  string `json:"unit"`
-		Trend string `json:"trend"`
-		Sort  int    `json:"sort"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Green) GreenDataCardCreate(c *gin.Context) {
+	var req GreenDataCardCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	card := models.GreenDataCard{
@@ -2360,28 +2619,21 @@ func GreenDataCardCreate(c *gin.Context) {
 		Trend: req.Trend,
 		Sort:  req.Sort,
 	}
-	if err := config.DB.Create(&card).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "创建失败"})
+	if err := h.requestDB(c).Create(&card).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功"})
 }
 
-func GreenDataCardUpdate(c *gin.Context) {
+func (h *Green) GreenDataCardUpdate(c *gin.Context) {
 	id := c.Param("id")
-	var req struct {
-		Icon  string `json:"icon"`
-		Title string `json:"title"`
-		Num   string `json:"num"`
-		Unit  string `json:"unit"`
-		Trend string `json:"trend"`
-		Sort  int    `json:"sort"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req GreenDataCardUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	if err := config.DB.Model(&models.GreenDataCard{}).Where("id = ?", id).Updates(map[string]interface{}{
+	if err := h.requestDB(c).Model(&models.GreenDataCard{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"icon":  req.Icon,
 		"title": req.Title,
 		"num":   req.Num,
@@ -2389,22 +2641,22 @@ func GreenDataCardUpdate(c *gin.Context) {
 		"trend": req.Trend,
 		"sort":  req.Sort,
 	}).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func GreenDataCardDelete(c *gin.Context) {
+func (h *Green) GreenDataCardDelete(c *gin.Context) {
 	id := c.Param("id")
-	if err := config.DB.Delete(&models.GreenDataCard{}, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+	if err := h.requestDB(c).Delete(&models.GreenDataCard{}, id).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func GreenQuestionList(c *gin.Context) {
+func (h *Green) GreenQuestionList(c *gin.Context) {
 	var list []models.GreenQuestion
 	p := int64(1)
 	s := int64(10)
@@ -2415,50 +2667,37 @@ func GreenQuestionList(c *gin.Context) {
 		s = v
 	}
 	offset := (p - 1) * s
-	query := config.DB.Model(&models.GreenQuestion{})
+	query := h.requestDB(c).Model(&models.GreenQuestion{})
 	var total int64
 	query.Count(&total)
 	if err := query.Offset(int(offset)).Limit(int(s)).Order("id desc").Find(&list).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
 	respondList(c, "请求成功", list, total)
 }
 
-func GreenQuestionCreate(c *gin.Context) {
+func (h *Green) GreenQuestionCreate(c *gin.Context) {
 	var req models.GreenQuestion
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
-	if err := config.DB.Create(&req).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "创建失败"})
+	if err := h.requestDB(c).Create(&req).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "创建失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "创建成功"})
 }
 
-func GreenQuestionUpdate(c *gin.Context) {
+func (h *Green) GreenQuestionUpdate(c *gin.Context) {
 	id := c.Param("id")
-	var req struct {
-		QuestionType string `json:"questionType"`
-		Level        string `json:"level"`
-		Question     string `json:"question"`
-		OptionA      string `json:"optionA"`
-		OptionB      string `json:"optionB"`
-		OptionC      string `json:"optionC"`
-		OptionD      string `json:"optionD"`
-		OptionE      string `json:"optionE"`
-		OptionF      string `json:"optionF"`
-		Answer       string `json:"answer"`
-		Score        int    `json:"score"`
-		Status       string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
-		return
-	}
-	if err := config.DB.Model(&models.GreenQuestion{}).Where("id = ?", id).Updates(map[string]interface{}{
+	var req GreenQuestionUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
+		return
+	}
+	if err := h.requestDB(c).Model(&models.GreenQuestion{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"question_type": req.QuestionType,
 		"level":         req.Level,
 		"question":      req.Question,
@@ -2472,37 +2711,37 @@ func GreenQuestionUpdate(c *gin.Context) {
 		"score":         req.Score,
 		"status":        req.Status,
 	}).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func GreenQuestionDelete(c *gin.Context) {
+func (h *Green) GreenQuestionDelete(c *gin.Context) {
 	id := c.Param("id")
-	if err := config.DB.Delete(&models.GreenQuestion{}, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+	if err := h.requestDB(c).Delete(&models.GreenQuestion{}, id).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
 
-func GreenDataSeriesByKey(c *gin.Context) {
+func (h *Green) GreenDataSeriesByKey(c *gin.Context) {
 	listKey := c.Param("listKey")
 	if listKey == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 
 	var record models.GreenDataSeries
-	if err := config.DB.Where("list_key = ?", listKey).First(&record).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 404, Msg: "数据不存在"})
+	if err := h.requestDB(c).Where("list_key = ?", listKey).First(&record).Error; err != nil {
+		fail(c, 404, middleware.ClassNotFound, "数据不存在")
 		return
 	}
 
 	var parsed []map[string]interface{}
 	if err := json.Unmarshal([]byte(record.Data), &parsed); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "数据解析失败"})
+		fail(c, 500, middleware.ClassInternal, "数据解析失败")
 		return
 	}
 
@@ -2521,42 +2760,41 @@ func GreenDataSeriesByKey(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "请求成功", Data: result})
 }
 
-func GreenDataSeriesList(c *gin.Context) {
+func (h *Green) GreenDataSeriesList(c *gin.Context) {
 	var list []models.GreenDataSeries
-	if err := config.DB.Order("CAST(SUBSTR(list_key, 6) AS INTEGER) asc, id asc").Find(&list).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "查询失败"})
+	if err := h.requestDB(c).Order("id asc").Find(&list).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "查询失败")
 		return
 	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return listKeyNum(list[i].ListKey) < listKeyNum(list[j].ListKey)
+	})
 	respondList(c, "请求成功", list, int64(len(list)))
 }
 
-func GreenDataSeriesCreate(c *gin.Context) {
-	var req struct {
-		Data string `json:"data"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+func (h *Green) GreenDataSeriesCreate(c *gin.Context) {
+	var req GreenDataSeriesCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Data == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "数据不能为空"})
+		fail(c, 500, middleware.ClassInvalidParams, "数据不能为空")
 		return
 	}
 
 	for i := 0; i < 5; i++ {
-		var maxNum int
-		rows, err := config.DB.Raw("SELECT list_key FROM green_data_series WHERE list_key LIKE 'list_%' ORDER BY CAST(SUBSTR(list_key, 6) AS INTEGER) DESC LIMIT 1").Rows()
-		if err != nil {
-			c.JSON(http.StatusOK, Response{Code: 500, Msg: "创建失败"})
+		var keys []string
+		if err := h.requestDB(c).Model(&models.GreenDataSeries{}).Where("list_key LIKE ?", "list_%").Pluck("list_key", &keys).Error; err != nil {
+			fail(c, 500, middleware.ClassInternal, "创建失败")
 			return
 		}
-		if rows.Next() {
-			var key string
-			if scanErr := rows.Scan(&key); scanErr == nil {
-				_, _ = fmt.Sscanf(key, "list_%d", &maxNum)
+		maxNum := 0
+		for _, key := range keys {
+			if n := listKeyNum(key); n > maxNum {
+				maxNum = n
 			}
 		}
-		rows.Close()
 
 		newListKey := fmt.Sprintf("list_%d", maxNum+1)
 		record := models.GreenDataSeries{
@@ -2565,11 +2803,11 @@ func GreenDataSeriesCreate(c *gin.Context) {
 			Data:    req.Data,
 			Sort:    maxNum + 1,
 		}
-		if err := config.DB.Create(&record).Error; err != nil {
+		if err := h.requestDB(c).Create(&record).Error; err != nil {
 			if isUniqueConstraintError(err) {
 				continue
 			}
-			c.JSON(http.StatusOK, Response{Code: 500, Msg: "创建失败"})
+			fail(c, 500, middleware.ClassInternal, "创建失败")
 			return
 		}
 
@@ -2577,7 +2815,16 @@ func GreenDataSeriesCreate(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{Code: 500, Msg: "创建失败，请重试"})
+	fail(c, 500, middleware.ClassInternal, "创建失败，请重试")
+}
+
+// listKeyNum returns N for "list_N" and 0 for anything else.
+func listKeyNum(key string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(key, "list_"))
+	if err != nil || !strings.HasPrefix(key, "list_") {
+		return 0
+	}
+	return n
 }
 
 func isUniqueConstraintError(err error) bool {
@@ -2588,46 +2835,29 @@ func isUniqueConstraintError(err error) bool {
 	return strings.Contains(msg, "unique constraint") || strings.Contains(msg, "duplic")
 }
 
-func GreenDataSeriesUpdate(c *gin.Context) {
+func (h *Green) GreenDataSeriesUpdate(c *gin.Context) {
 	id := c.Param("id")
-	var req struct {
-		Data string `json:"data"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "参数错误"})
+	var req GreenDataSeriesUpdateRequest
+	if err := bindJSON(c, &req); err != nil {
+		fail(c, 500, middleware.ClassInvalidParams, "参数错误")
 		return
 	}
 	if req.Data == "" {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "数据不能为空"})
+		fail(c, 500, middleware.ClassInvalidParams, "数据不能为空")
 		return
 	}
-	if err := config.DB.Model(&models.GreenDataSeries{}).Where("id = ?", id).Update("data", req.Data).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "更新失败"})
+	if err := h.requestDB(c).Model(&models.GreenDataSeries{}).Where("id = ?", id).Update("data", req.Data).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "更新失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "更新成功"})
 }
 
-func GreenDataSeriesDelete(c *gin.Context) {
+func (h *Green) GreenDataSeriesDelete(c *gin.Context) {
 	id := c.Param("id")
-	if err := config.DB.Delete(&models.GreenDataSeries{}, id).Error; err != nil {
-		c.JSON(http.StatusOK, Response{Code: 500, Msg: "删除失败"})
+	if err := h.requestDB(c).Delete(&models.GreenDataSeries{}, id).Error; err != nil {
+		fail(c, 500, middleware.ClassInternal, "删除失败")
 		return
 	}
 	c.JSON(http.StatusOK, Response{Code: 200, Msg: "删除成功"})
 }
-
-func generateToken(userId int, userName, nickName, phone string) (string, error) {
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"userId":   userId,
-		"userName": userName,
-		"nickName": nickName,
-		"phone":    phone,
-		"iat":      now.Unix(),
-		"nbf":      now.Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte("digital-community-secret-key-2024"))
-}
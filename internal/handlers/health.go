@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"digital-community/internal/buildinfo"
+	"digital-community/internal/logging"
+	"digital-community/internal/migrations"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *System) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "ok"})
+}
+
+// Ready reports 503 when a dependency check fails so orchestrators stop
+// routing traffic to this instance. The endpoint is public, so failures are
+// only named here and the details go to the log.
+func (h *System) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := gin.H{"database": "ok", "uploadDir": "ok"}
+	ready := true
+	if err := h.pingDB(ctx); err != nil {
+		logging.L(c).Error("readiness check failed", "check", "database", "error", err)
+		checks["database"] = "error"
+		ready = false
+	}
+	if err := checkWritableDir(h.cfg.UploadRoot); err != nil {
+		logging.L(c).Error("readiness check failed", "check", "uploadDir", "error", err)
+		checks["uploadDir"] = "error"
+		ready = false
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, Response{Code: 503, Msg: "not ready", Data: checks})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "ok", Data: checks})
+}
+
+func (h *System) pingDB(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkWritableDir never creates dir: a missing directory usually means the
+// volume is not mounted, and the probe must report that.
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	f, err := os.CreateTemp(dir, ".ready-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+func (h *System) Version(c *gin.Context) {
+	schemaVersion, err := migrations.Current(h.requestDB(c))
+	if err != nil {
+		schemaVersion = -1
+	}
+	c.JSON(http.StatusOK, Response{Code: 200, Msg: "ok", Data: gin.H{
+		"version":       buildinfo.Version,
+		"commit":        buildinfo.CommitHash(),
+		"buildTime":     buildinfo.BuildTime,
+		"startTime":     buildinfo.StartTime.Format("2006-01-02 15:04:05"),
+		"uptimeSeconds": int(time.Since(buildinfo.StartTime).Seconds()),
+		"schemaVersion": schemaVersion,
+		"latestSchema":  migrations.LatestSupported(h.requestDB(c)),
+	}})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import "time"
+
+// Request bodies bound by the handlers. They are named so the OpenAPI
+// document can be generated from them.
+
+type PasswordVerifyRequest struct {
+	Phone   string `json:"phone" binding:"required"`
+	SMSCode string `json:"smsCode" binding:"required"`
+}
+
+type PasswordResetRequest struct {
+	ResetToken  string `json:"resetToken" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type PhoneLoginRequest struct {
+	Phone      string `json:"phone" binding:"required"`
+	SMSCode    string `json:"smsCode"`
+	LegacyCode string `json:"SMSCode"`
+}
+
+type LoginRequest struct {
+	UserName       string `json:"userName" binding:"required"`
+	Password       string `json:"password"`
+	LegacyPassword string `json:"passWord"`
+}
+
+type RegisterRequest struct {
+	Avatar       string `json:"avatar"`
+	UserName     string `json:"userName" binding:"required"`
+	NickName     string `json:"nickName"`
+	Password     string `json:"password"`
+	PassWord     string `json:"passWord"`
+	PhoneNumber  string `json:"phoneNumber"`
+	Phonenumber  string `json:"phonenumber"`
+	Sex          string `json:"sex" binding:"required"`
+	Email        string `json:"email"`
+	IDCard       string `json:"idCard"`
+	Address      string `json:"address"`
+	Introduction string `json:"introduction"`
+}
+
+type UpdateUserInfoRequest struct {
+	Avatar       string `json:"avatar"`
+	NickName     string `json:"nickName" binding:"required"`
+	PhoneNumber  string `json:"phoneNumber"`
+	Phonenumber  string `json:"phonenumber"`
+	Sex          string `json:"sex"`
+	Email        string `json:"email"`
+	IDCard       string `json:"idCard"`
+	Address      string `json:"address"`
+	Introduction string `json:"introduction"`
+}
+
+type ResetPwdRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type UserCreateRequest struct {
+	UserName     string `json:"userName" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	NickName     string `json:"nickName"`
+	Phone        string `json:"phone" binding:"required"`
+	Sex          string `json:"sex"`
+	Email        string `json:"email"`
+	Avatar       string `json:"avatar"`
+	Address      string `json:"address"`
+	Introduction string `json:"introduction"`
+	Role         string `json:"role"`
+}
+
+type UserUpdateRequest struct {
+	NickName     string `json:"nickName"`
+	Phone        string `json:"phone"`
+	Sex          string `json:"sex"`
+	Email        string `json:"email"`
+	Avatar       string `json:"avatar"`
+	Address      string `json:"address"`
+	Introduction string `json:"introduction"`
+	Status       string `json:"status"`
+	Role         string `json:"role"`
+}
+
+type RotationCreateRequest struct {
+	Title   string `json:"title" binding:"required"`
+	PicPath string `json:"picPath"`
+	Link    string `json:"link"`
+	Type    int    `json:"type" binding:"required"`
+}
+
+type RotationUpdateRequest struct {
+	Title   string `json:"title"`
+	PicPath string `json:"picPath"`
+	Link    string `json:"link"`
+	Type    int    `json:"type"`
+	Status  string `json:"status"`
+}
+
+type PressCategoryCreateRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Sort   int    `json:"sort"`
+	Status string `json:"status"`
+}
+
+type PressCategoryUpdateRequest struct {
+	Name   string `json:"name"`
+	Sort   int    `json:"sort"`
+	Status string `json:"status"`
+}
+
+type PressNewsCreateRequest struct {
+	Title      string `json:"title" binding:"required"`
+	SubTitle   string `json:"subTitle"`
+	Content    string `json:"content" binding:"required"`
+	CategoryId int    `json:"categoryId" binding:"required"`
+	Type       string `json:"type"`
+	ImageUrls  string `json:"imageUrls"`
+	Top        string `json:"top" binding:"omitempty,oneof=Y N"`
+	Hot        string `json:"hot" binding:"omitempty,oneof=Y N"`
+	Tags       string `json:"tags"`
+}
+
+type PressNewsUpdateRequest struct {
+	Title      string `json:"title"`
+	SubTitle   string `json:"subTitle"`
+	Content    string `json:"content"`
+	CategoryId int    `json:"categoryId"`
+	Type       string `json:"type"`
+	ImageUrls  string `json:"imageUrls"`
+	Status     string `json:"status"`
+	Top        string `json:"top" binding:"omitempty,oneof=Y N"`
+	Hot        string `json:"hot" binding:"omitempty,oneof=Y N"`
+	Tags       string `json:"tags"`
+}
+
+type PressCommentRequest struct {
+	NewsID   string `json:"newsId" binding:"required"`
+	Content  string `json:"content" binding:"required"`
+	UserName string `json:"userName" binding:"required"`
+}
+
+type NoticeCreateRequest struct {
+	Title         string `json:"title" binding:"required"`
+	NoticeContent string `json:"noticeContent" binding:"required"`
+	NoticeStatus  string `json:"noticeStatus"`
+	CreateBy      string `json:"createBy"`
+}
+
+type NoticeUpdateRequest struct {
+	Title         string `json:"title"`
+	NoticeContent string `json:"noticeContent"`
+	NoticeStatus  string `json:"noticeStatus"`
+	CreateBy      string `json:"createBy"`
+}
+
+type FriendlyNeighborAddCommentRequest struct {
+	NeighborhoodID int    `json:"neighborhoodId" binding:"required"`
+	Content        string `json:"content" binding:"required"`
+}
+
+type FriendlyNeighborCreateRequest struct {
+	Content    string `json:"content" binding:"required"`
+	ImgUrl     string `json:"imgUrl"`
+	UserId     int    `json:"userId"`
+	NickName   string `json:"nickName"`
+	UserImgUrl string `json:"userImgUrl"`
+}
+
+type FriendlyNeighborUpdateRequest struct {
+	Content    string `json:"content"`
+	NickName   string `json:"nickName"`
+	ImgUrl     string `json:"imgUrl"`
+	UserImgUrl string `json:"userImgUrl"`
+}
+
+type ActivitySearchRequest struct {
+	Words string `json:"words" binding:"required"`
+}
+
+type ActivityCreateRequest struct {
+	Title      string    `json:"title" binding:"required"`
+	Content    string    `json:"content" binding:"required"`
+	PicPath    string    `json:"picPath"`
+	CategoryId int       `json:"categoryId" binding:"required"`
+	StartDate  time.Time `json:"startDate"`
+	EndDate    time.Time `json:"endDate"`
+	Address    string    `json:"address"`
+	TotalCount int       `json:"totalCount"`
+	IsTop      string    `json:"isTop"`
+	CreateBy   string    `json:"createBy"`
+}
+
+type ActivityUpdateRequest struct {
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	PicPath    string    `json:"picPath"`
+	CategoryId int       `json:"categoryId"`
+	StartDate  time.Time `json:"startDate"`
+	EndDate    time.Time `json:"endDate"`
+	Address    string    `json:"address"`
+	TotalCount int       `json:"totalCount"`
+	IsTop      string    `json:"isTop"`
+	Status     string    `json:"status"`
+	CreateBy   string    `json:"createBy"`
+}
+
+type RegistrationRequest struct {
+	ActivityId int `json:"activityId" binding:"required"`
+}
+
+type RegistrationCommentRequest struct {
+	Evaluate string `json:"evaluate" binding:"required"`
+	Star     int    `json:"star" binding:"required"`
+}
+
+type QuestionSavePaperRequest struct {
+	Score  interface{} `json:"score"`
+	Answer []struct {
+		Qid    int    `json:"qid"`
+		Answer string `json:"answer"`
+	} `json:"answer"`
+}
+
+type GreenDataCardCreateRequest struct {
+	Icon  string `json:"icon"`
+	Title string `json:"title"`
+	Num   string `json:"num"`
+	Unit  string `json:"unit"`
+	Trend string `json:"trend"`
+	Sort  int    `json:"sort"`
+}
+
+type GreenDataCardUpdateRequest struct {
+	Icon  string `json:"icon"`
+	Title string `json:"title"`
+	Num   string `json:"num"`
+	Unit  string `json:"unit"`
+	Trend string `json:"trend"`
+	Sort  int    `json:"sort"`
+}
+
+type GreenQuestionUpdateRequest struct {
+	QuestionType string `json:"questionType"`
+	Level        string `json:"level"`
+	Question     string `json:"question"`
+	OptionA      string `json:"optionA"`
+	OptionB      string `json:"optionB"`
+	OptionC      string `json:"optionC"`
+	OptionD      string `json:"optionD"`
+	OptionE      string `json:"optionE"`
+	OptionF      string `json:"optionF"`
+	Answer       string `json:"answer"`
+	Score        int    `json:"score"`
+	Status       string `json:"status"`
+}
+
+type GreenDataSeriesCreateRequest struct {
+	Data string `json:"data"`
+}
+
+type GreenDataSeriesUpdateRequest struct {
+	Data string `json:"data"`
+}
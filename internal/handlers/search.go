@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"digital-community/internal/middleware"
+	"digital-community/internal/models"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Highlight markers from the private use area, swapped for <em> after the
+	// text around them has been escaped.
+	markOpen  = "\uE000"
+	markClose = "\uE001"
+
+	maxSearchKeyword = 64
+	// The trigram tokenizer cannot match terms shorter than this.
+	minIndexedTerm = 3
+	snippetRunes   = 60
+)
+
+var searchKinds = []string{"news", "notice", "activity", "neighbor"}
+
+var (
+	htmlTagPattern   = regexp.MustCompile(`<[^<>]*>|^[^<>]*>|<[^<>]*$`)
+	highlightPattern = regexp.MustCompile(markOpen + `|` + markClose)
+)
+
+type SearchHit struct {
+	Type    string `json:"type"`
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+	Date    string `json:"date"`
+}
+
+// SearchAll looks up news, notices, activities and neighbor posts in one go.
+// Ranked FTS5 matching is used when the search index exists and every term
+// is long enough for the trigram tokenizer; otherwise it falls back to LIKE.
+func (h *Search) SearchAll(c *gin.Context) {
+	pageNum, pageSize := h.parsePaging(c)
+	keyword := strings.TrimSpace(c.Query("keyword"))
+	if keyword == "" {
+		fail(c, 500, middleware.ClassInvalidParams, "关键词不能为空")
+		return
+	}
+	if utf8.RuneCountInString(keyword) > maxSearchKeyword {
+		fail(c, 500, middleware.ClassInvalidParams, "关键词过长")
+		return
+	}
+	kinds, ok := parseSearchKinds(c.Query("type"))
+	if !ok {
+		fail(c, 500, middleware.ClassInvalidParams, "类型参数错误")
+		return
+	}
+
+	terms := strings.Fields(keyword)
+	offset := (pageNum - 1) * pageSize
+	var hits []SearchHit
+	var total int64
+	var err error
+	if h.indexed && shortestTerm(terms) >= minIndexedTerm {
+		hits, total, err = h.matchIndex(h.requestDB(c), terms, kinds, offset, pageSize)
+	} else {
+		hits, total, err = h.scanTables(h.requestDB(c), terms, kinds, offset, pageSize)
+	}
+	if err != nil {
+		fail(c, 500, middleware.ClassInternal, "搜索失败")
+		return
+	}
+	respondList(c, "请求成功", hits, total)
+}
+
+// parseSearchKinds reads a comma separated type filter; empty means all.
+func parseSearchKinds(raw string) ([]string, bool) {
+	if strings.TrimSpace(raw) == "" {
+		return searchKinds, true
+	}
+	var kinds []string
+	for _, k := range strings.Split(raw, ",") {
+		k = strings.TrimSpace(k)
+		valid := false
+		for _, known := range searchKinds {
+			if k == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, false
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, true
+}
+
+func shortestTerm(terms []string) int {
+	shortest := -1
+	for _, t := range terms {
+		if n := utf8.RuneCountInString(t); shortest < 0 || n < shortest {
+			shortest = n
+		}
+	}
+	return shortest
+}
+
+func (h *Search) matchIndex(db *gorm.DB, terms, kinds []string, offset, limit int) ([]SearchHit, int64, error) {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	query := db.Table("search_index").Where("search_index MATCH ? AND kind IN ?", strings.Join(quoted, " "), kinds)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []struct {
+		Kind    string
+		RefID   int
+		Date    string
+		Title   string
+		Snippet string
+	}
+	// Title matches weigh ten times as much as body matches.
+	err := query.Select("kind, ref_id, date, highlight(search_index, 3, ?, ?) AS title, snippet(search_index, 4, ?, ?, '…', 24) AS snippet",
+		markOpen, markClose, markOpen, markClose).
+		Order("bm25(search_index, 0, 0, 0, 10.0, 1.0)").
+		Offset(offset).Limit(limit).Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]SearchHit, 0, len(rows))
+	for _, r := range rows {
+		date := r.Date
+		if strings.HasPrefix(date, "0001-") {
+			date = ""
+		}
+		hits = append(hits, SearchHit{
+			Type:    r.Kind,
+			ID:      r.RefID,
+			Title:   renderHighlight(cleanSearchText(r.Kind, r.Title)),
+			Snippet: renderHighlight(cleanSearchText(r.Kind, r.Snippet)),
+			Date:    date,
+		})
+	}
+	return hits, total, nil
+}
+
+type searchDoc struct {
+	id    uint
+	title string
+	body  string
+	date  time.Time
+}
+
+// searchTable is the LIKE fallback view of one searchable model; columns
+// lists what is matched, title column first.
+type searchTable struct {
+	model   interface{}
+	date    string
+	columns []string
+	load    func(q *gorm.DB) ([]searchDoc, error)
+}
+
+func loadDocs[T any](q *gorm.DB, doc func(T) searchDoc) ([]searchDoc, error) {
+	var rows []T
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]searchDoc, 0, len(rows))
+	for _, r := range rows {
+		docs = append(docs, doc(r))
+	}
+	return docs, nil
+}
+
+var searchTables = map[string]searchTable{
+	"news": {model: &models.PressNews{}, date: "publish_date", columns: []string{"title", "sub_title", "content"},
+		load: func(q *gorm.DB) ([]searchDoc, error) {
+			return loadDocs(q, func(r models.PressNews) searchDoc {
+				return searchDoc{id: r.ID, title: r.Title, body: r.SubTitle + " " + r.Content, date: r.PublishDate}
+			})
+		}},
+	"notice": {model: &models.Notice{}, date: "publish_date", columns: []string{"title", "notice_content"},
+		load: func(q *gorm.DB) ([]searchDoc, error) {
+			return loadDocs(q, func(r models.Notice) searchDoc {
+				return searchDoc{id: r.ID, title: r.Title, body: r.NoticeContent, date: r.PublishDate}
+			})
+		}},
+	"activity": {model: &models.Activity{}, date: "start_date", columns: []string{"title", "content", "address"},
+		load: func(q *gorm.DB) ([]searchDoc, error) {
+			return loadDocs(q, func(r models.Activity) searchDoc {
+				return searchDoc{id: r.ID, title: r.Title, body: r.Content + " " + r.Address, date: r.StartDate}
+			})
+		}},
+	"neighbor": {model: &models.FriendlyNeighbor{}, date: "created_at", columns: []string{"nick_name", "content"},
+		load: func(q *gorm.DB) ([]searchDoc, error) {
+			return loadDocs(q, func(r models.FriendlyNeighbor) searchDoc {
+				return searchDoc{id: r.ID, title: r.NickName, body: r.Content, date: r.CreatedAt}
+			})
+		}},
+}
+
+// scanTables is the LIKE fallback. Matching, counting, ranking and paging
+// all happen in SQL over a UNION of the tables; only the rows of the page
+// are loaded to build snippets.
+func (h *Search) scanTables(db *gorm.DB, terms, kinds []string, offset, limit int) ([]SearchHit, int64, error) {
+	parts := make([]string, len(kinds))
+	args := make([]interface{}, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = "?"
+		args[i] = likeQuery(db, kind, terms)
+	}
+	matches := db.Raw(strings.Join(parts, " UNION ALL "), args...)
+
+	var total int64
+	if err := db.Table("(?) AS hits", matches).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var page []struct {
+		Kind string
+		ID   uint
+	}
+	err := db.Table("(?) AS hits", matches).Select("kind, id").
+		Order("score DESC, sort_date DESC, kind, id DESC").
+		Offset(offset).Limit(limit).Scan(&page).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := map[string][]uint{}
+	for _, row := range page {
+		ids[row.Kind] = append(ids[row.Kind], row.ID)
+	}
+	docs := map[string]map[uint]searchDoc{}
+	for kind, kindIDs := range ids {
+		found, err := searchTables[kind].load(db.Where("id IN ?", kindIDs))
+		if err != nil {
+			return nil, 0, err
+		}
+		docs[kind] = make(map[uint]searchDoc, len(found))
+		for _, d := range found {
+			docs[kind][d.id] = d
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(page))
+	for _, row := range page {
+		d, ok := docs[row.Kind][row.ID]
+		if !ok {
+			continue
+		}
+		date := ""
+		if !d.date.IsZero() {
+			date = d.date.UTC().Format("2006-01-02 15:04:05")
+		}
+		body := cleanSearchText(row.Kind, d.body)
+		hits = append(hits, SearchHit{
+			Type:    row.Kind,
+			ID:      int(d.id),
+			Title:   renderHighlight(markTerms(d.title, terms)),
+			Snippet: renderHighlight(markTerms(snippetAround(body, terms), terms)),
+			Date:    date,
+		})
+	}
+	return hits, total, nil
+}
+
+// likeQuery selects the rows of one table that contain every term, scored
+// by how often the terms occur: ten points per title hit, one per body hit.
+// Occurrences are counted by how much the text shrinks when a term is
+// removed, which works the same on SQLite, MySQL and PostgreSQL. Matching
+// lowercases both sides and escapes wildcards so it agrees with the score.
+func likeQuery(db *gorm.DB, kind string, terms []string) *gorm.DB {
+	table := searchTables[kind]
+	var score []string
+	var scoreArgs []interface{}
+	q := db.Model(table.model)
+	for _, t := range terms {
+		lower := strings.ToLower(t)
+		// MySQL's LENGTH counts bytes, the others count characters.
+		n := utf8.RuneCountInString(lower)
+		if db.Dialector.Name() == "mysql" {
+			n = len(lower)
+		}
+		conds := make([]string, len(table.columns))
+		likeArgs := make([]interface{}, len(table.columns))
+		for i, col := range table.columns {
+			conds[i] = "LOWER(" + col + ") LIKE ? ESCAPE '!'"
+			likeArgs[i] = "%" + escapeLike(lower) + "%"
+			weight := 1
+			if i == 0 {
+				weight = 10
+			}
+			score = append(score, fmt.Sprintf("%d * (LENGTH(COALESCE(%s, '')) - LENGTH(REPLACE(LOWER(COALESCE(%s, '')), ?, ''))) / %d", weight, col, col, n))
+			scoreArgs = append(scoreArgs, lower)
+		}
+		q = q.Where("("+strings.Join(conds, " OR ")+")", likeArgs...)
+	}
+	return q.Select(fmt.Sprintf("'%s' AS kind, id, %s AS sort_date, %s AS score", kind, table.date, strings.Join(score, " + ")), scoreArgs...)
+}
+
+// cleanSearchText drops markup from rich-text kinds and collapses whitespace.
+// Neighbor posts are plain text and only get their whitespace collapsed.
+func cleanSearchText(kind, s string) string {
+	if kind != "neighbor" {
+		s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// snippetAround cuts a window of text around the first term found.
+func snippetAround(text string, terms []string) string {
+	runes := []rune(text)
+	if len(runes) <= snippetRunes {
+		return text
+	}
+	lower := strings.ToLower(text)
+	start := 0
+	for _, t := range terms {
+		if i := strings.Index(lower, strings.ToLower(t)); i >= 0 {
+			start = max(utf8.RuneCountInString(lower[:i])-snippetRunes/4, 0)
+			break
+		}
+	}
+	end := min(start+snippetRunes, len(runes))
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// markTerms wraps case-insensitive occurrences of terms in highlight markers.
+func markTerms(text string, terms []string) string {
+	if len(terms) == 0 {
+		return text
+	}
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	re := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	return re.ReplaceAllString(text, markOpen+"$0"+markClose)
+}
+
+// renderHighlight escapes text for HTML and turns highlight markers into
+// <em> tags, so clients can render snippets as markup safely.
+func renderHighlight(s string) string {
+	return highlightPattern.ReplaceAllStringFunc(html.EscapeString(s), func(m string) string {
+		if m == markOpen {
+			return "<em>"
+		}
+		return "</em>"
+	})
+}
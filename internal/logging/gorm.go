@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger reports failed and slow queries through the slog logger found in
+// the query context. Record-not-found is expected control flow and skipped.
+type GormLogger struct {
+	SlowThreshold time.Duration
+	level         logger.LogLevel
+}
+
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{SlowThreshold: slowThreshold, level: logger.Warn}
+}
+
+func (g *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *g
+	clone.level = level
+	return &clone
+}
+
+func (g *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Info {
+		FromContext(ctx).InfoContext(ctx, msg, "args", args)
+	}
+}
+
+func (g *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Warn {
+		FromContext(ctx).WarnContext(ctx, msg, "args", args)
+	}
+}
+
+func (g *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Error {
+		FromContext(ctx).ErrorContext(ctx, msg, "args", args)
+	}
+}
+
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= logger.Error:
+		sql, rows := fc()
+		FromContext(ctx).ErrorContext(ctx, "db query failed",
+			slog.String("error", err.Error()),
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("elapsed_ms", float64(elapsed.Microseconds())/1000))
+	case g.SlowThreshold > 0 && elapsed > g.SlowThreshold && g.level >= logger.Warn:
+		sql, rows := fc()
+		FromContext(ctx).WarnContext(ctx, "slow db query",
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("elapsed_ms", float64(elapsed.Microseconds())/1000))
+	case g.level >= logger.Info:
+		sql, rows := fc()
+		FromContext(ctx).DebugContext(ctx, "db query",
+			slog.String("sql", sql),
+			slog.Int64("rows", rows),
+			slog.Float64("elapsed_ms", float64(elapsed.Microseconds())/1000))
+	}
+}
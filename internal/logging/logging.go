@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ctxKey struct{}
+
+// Setup installs a JSON slog handler as the process default. The standard
+// library log package is routed through it as well.
+func Setup(level string) {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})))
+}
+
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// With attaches attributes to the request-scoped logger, so everything logged
+// later in the request (including GORM errors) carries them.
+func With(c *gin.Context, args ...any) {
+	l := FromContext(c.Request.Context()).With(args...)
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), l))
+}
+
+func L(c *gin.Context) *slog.Logger {
+	return FromContext(c.Request.Context())
+}
+
+// MaskPhone keeps the first three and last four digits, enough to tell
+// numbers apart in logs without recording them.
+func MaskPhone(phone string) string {
+	if len(phone) < 8 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-7) + phone[len(phone)-4:]
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "digital_community"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template and status.",
+	}, []string{"method", "route", "status"})
+
+	thumbnails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "thumbnail_requests_total",
+		Help:      "Thumbnail lookups: hit (cached), miss (generated) or failure.",
+	}, []string{"result"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes accepted by the upload endpoint.",
+	}, []string{"kind"})
+
+	uploadFiles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_files_total",
+		Help:      "Files accepted by the upload endpoint.",
+	}, []string{"kind"})
+)
+
+const (
+	ThumbnailHit     = "hit"
+	ThumbnailMiss    = "miss"
+	ThumbnailFailure = "failure"
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestDuration,
+		requestsTotal,
+		thumbnails,
+		uploadBytes,
+		uploadFiles,
+	)
+	for _, result := range []string{ThumbnailHit, ThumbnailMiss, ThumbnailFailure} {
+		thumbnails.WithLabelValues(result)
+	}
+}
+
+// RegisterDB exposes connection pool stats for db. Call it once per process.
+func RegisterDB(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware labels requests with the gin route template (e.g.
+// /prod-api/api/press/news/:id) so label cardinality stays bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveThumbnail(result string) {
+	thumbnails.WithLabelValues(result).Inc()
+}
+
+func ObserveUpload(kind string, size int64) {
+	uploadBytes.WithLabelValues(kind).Add(float64(size))
+	uploadFiles.WithLabelValues(kind).Inc()
+}
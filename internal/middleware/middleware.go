@@ -1,8 +1,17 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"digital-community/internal/auth"
+	"digital-community/internal/logging"
+	"digital-community/internal/models"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -13,10 +22,41 @@ type Claims struct {
 	UserName string `json:"userName"`
 	NickName string `json:"nickName"`
 	Phone    string `json:"phone"`
+	Role     string `json:"role"`
+	// TokenVersion must match the user's current version; bumping it on the
+	// user row revokes every token issued before.
+	TokenVersion int `json:"ver"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+type SessionChecker interface {
+	IsRevoked(jti string) (bool, error)
+	UserState(userId int) (auth.UserState, error)
+}
+
+const (
+	PermContentManage = "content:manage"
+	PermMediaManage   = "media:manage"
+	PermUserManage    = "user:manage"
+	PermSystemManage  = "system:manage"
+)
+
+var rolePermissions = map[string][]string{
+	models.RoleResident: {},
+	models.RoleEditor:   {PermContentManage, PermMediaManage},
+	models.RoleAdmin:    {PermContentManage, PermMediaManage, PermUserManage, PermSystemManage},
+}
+
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[models.NormalizeRole(role)] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func AuthMiddleware(secret string, sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -39,27 +79,100 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			return []byte(secret), nil
-		})
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "未授权"})
 			c.Abort()
 			return
 		}
+		role := claims.Role
+		if sessions != nil {
+			state, msg := checkSession(sessions, claims)
+			if msg != "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+				c.Abort()
+				return
+			}
+			role = state.Role
+		}
 
 		c.Set("userId", claims.UserID)
 		c.Set("userName", claims.UserName)
 		c.Set("nickName", claims.NickName)
 		c.Set("phone", claims.Phone)
+		c.Set("role", models.NormalizeRole(role))
+		c.Set("tokenId", claims.ID)
+		logging.With(c, "user_id", claims.UserID)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
 		c.Next()
 	}
 }
 
-func CORSMiddleware() gin.HandlerFunc {
+func checkSession(sessions SessionChecker, claims *Claims) (auth.UserState, string) {
+	if claims.ID == "" {
+		return auth.UserState{}, "登录已失效"
+	}
+	revoked, err := sessions.IsRevoked(claims.ID)
+	if err != nil || revoked {
+		return auth.UserState{}, "登录已失效"
+	}
+	state, err := sessions.UserState(claims.UserID)
+	if err != nil {
+		return state, "登录已失效"
+	}
+	if !state.Active {
+		return state, "账号已停用"
+	}
+	if state.TokenVersion != claims.TokenVersion {
+		return state, "登录已失效"
+	}
+	return state, ""
+}
+
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if !HasPermission(c.GetString("role"), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "无权限"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CORSMiddleware allows the listed origins; "*" allows any. Requests from
+// other origins get no CORS headers, so browsers block them.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+	return func(c *gin.Context) {
+		if allowAll {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			origin := c.GetHeader("Origin")
+			if !allowed[origin] {
+				if c.Request.Method == "OPTIONS" {
+					c.AbortWithStatus(http.StatusForbidden)
+					return
+				}
+				c.Next()
+				return
+			}
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, X-Response-Mode")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -71,8 +184,80 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// StaticTokenMiddleware admits requests whose bearer token equals token.
+// It guards machine endpoints such as /metrics that have no user session.
+func StaticTokenMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "未授权"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware reuses a well-formed incoming X-Request-ID (so IDs set
+// by nginx or the client survive) and otherwise generates one. It also seeds
+// the request-scoped logger.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id, _ = auth.RandomToken(16)
+		}
+		c.Set("requestId", id)
+		c.Writer.Header().Set(RequestIDHeader, id)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		l := slog.Default().With("request_id", id, "method", c.Request.Method, "route", route)
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), l))
+		c.Next()
+	}
+}
+
+// RecoveryMiddleware replaces gin.Recovery so panics are logged as JSON with
+// the request ID instead of as a plain-text dump.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				logging.L(c).Error("panic recovered", "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "系统异常"})
+			}
+		}()
+		c.Next()
+	}
+}
+
 func LoggerMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithConfig(gin.LoggerConfig{
-		SkipPaths: []string{"/health"},
-	})
+	skip := map[string]bool{"/health": true, "/ready": true, "/metrics": true}
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+		if skip[path] {
+			return
+		}
+
+		attrs := []any{
+			"path", path,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, "errors", errs)
+		}
+		logging.L(c).Info("request", attrs...)
+	}
 }
@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const ResponseModeHeader = "X-Response-Mode"
+
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ErrorClass is the HTTP status and errorCode strict mode reports for a
+// failed response. Handlers keep their compatible body codes (mostly 500) and
+// attach the class with SetErrorClass; without one, as for the replies written
+// by this package, the body's code decides.
+type ErrorClass struct {
+	Status    int
+	ErrorCode string
+}
+
+var (
+	ClassInvalidParams      = ErrorClass{http.StatusBadRequest, "INVALID_PARAMS"}
+	ClassInvalidSMSCode     = ErrorClass{http.StatusBadRequest, "INVALID_SMS_CODE"}
+	ClassWrongPassword      = ErrorClass{http.StatusBadRequest, "WRONG_PASSWORD"}
+	ClassResetTokenInvalid  = ErrorClass{http.StatusBadRequest, "RESET_TOKEN_INVALID"}
+	ClassUnauthorized       = ErrorClass{http.StatusUnauthorized, "UNAUTHORIZED"}
+	ClassInvalidCredentials = ErrorClass{http.StatusUnauthorized, "INVALID_CREDENTIALS"}
+	ClassForbidden          = ErrorClass{http.StatusForbidden, "FORBIDDEN"}
+	ClassAccountDisabled    = ErrorClass{http.StatusForbidden, "ACCOUNT_DISABLED"}
+	ClassNotFound           = ErrorClass{http.StatusNotFound, "NOT_FOUND"}
+	ClassConflict           = ErrorClass{http.StatusConflict, "CONFLICT"}
+	ClassTooManyRequests    = ErrorClass{http.StatusTooManyRequests, "TOO_MANY_REQUESTS"}
+	ClassInternal           = ErrorClass{http.StatusInternalServerError, "INTERNAL_ERROR"}
+)
+
+var codeClasses = map[int]ErrorClass{
+	http.StatusBadRequest:      ClassInvalidParams,
+	http.StatusUnauthorized:    ClassUnauthorized,
+	http.StatusForbidden:       ClassForbidden,
+	http.StatusNotFound:        ClassNotFound,
+	http.StatusConflict:        ClassConflict,
+	http.StatusTooManyRequests: ClassTooManyRequests,
+}
+
+const errorClassKey = "errorClass"
+
+// SetErrorClass tells strict mode how to report the failure the handler is
+// about to write.
+func SetErrorClass(c *gin.Context, class ErrorClass) {
+	c.Set(errorClassKey, class)
+}
+
+func classify(c *gin.Context, code int) ErrorClass {
+	if v, ok := c.Get(errorClassKey); ok {
+		return v.(ErrorClass)
+	}
+	if class, ok := codeClasses[code]; ok {
+		return class
+	}
+	return ClassInternal
+}
+
+// RegisterFieldNames makes validation errors name fields by their JSON key,
+// which is what strict mode reports in errors[].field. It changes gin's
+// shared validator, so it is called once at startup.
+func RegisterFieldNames() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// StrictStatusMiddleware makes the HTTP status mirror the body's code and adds
+// errorCode plus per-field binding details. It applies to every request when
+// enabled, otherwise only to requests sending "X-Response-Mode: strict".
+func StrictStatusMiddleware(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled && !strings.EqualFold(c.GetHeader(ResponseModeHeader), "strict") {
+			c.Next()
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+
+		body := w.body.Bytes()
+		status := w.status
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			body, status = rewriteBody(c, body, status)
+		}
+		c.Writer.WriteHeader(status)
+		_, _ = c.Writer.Write(body)
+	}
+}
+
+func rewriteBody(c *gin.Context, body []byte, status int) ([]byte, int) {
+	var envelope struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Code == nil {
+		return body, status
+	}
+	code := *envelope.Code
+	if code == http.StatusOK {
+		return body, http.StatusOK
+	}
+
+	class := classify(c, code)
+	extra := map[string]interface{}{"errorCode": class.ErrorCode}
+	if details := bindingDetails(c); len(details) > 0 {
+		extra["errors"] = details
+		class.Status = http.StatusBadRequest
+	}
+	return appendFields(body, extra), class.Status
+}
+
+func appendFields(body []byte, fields map[string]interface{}) []byte {
+	trimmed := bytes.TrimRight(body, " \r\n\t")
+	if len(trimmed) < 2 || trimmed[len(trimmed)-1] != '}' {
+		return body
+	}
+	var buf bytes.Buffer
+	buf.Write(trimmed[:len(trimmed)-1])
+	for _, key := range []string{"errorCode", "errors"} {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		buf.WriteString(`,"` + key + `":`)
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+func bindingDetails(c *gin.Context) []FieldError {
+	var details []FieldError
+	for _, e := range c.Errors.ByType(gin.ErrorTypeBind) {
+		details = append(details, fieldErrors(e.Err)...)
+	}
+	return details
+}
+
+func fieldErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: ruleMessage(fe.Tag())})
+		}
+		return details
+	case errors.As(err, &typeErr):
+		return []FieldError{{Field: typeErr.Field, Rule: "type", Message: "类型错误，应为" + typeErr.Type.String()}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{Rule: "json", Message: "请求体不是合法的JSON"}}
+	default:
+		return []FieldError{{Rule: "invalid", Message: err.Error()}}
+	}
+}
+
+func ruleMessage(tag string) string {
+	switch tag {
+	case "required":
+		return "不能为空"
+	case "min", "max", "len":
+		return "长度或取值超出范围"
+	case "oneof":
+		return "取值不在允许范围内"
+	default:
+		return "格式不正确"
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// bufferedWriter holds the response until the handler chain finishes so the
+// status can still be changed after c.JSON has been called. Non-JSON bodies
+// (file downloads) are never rewritten, so they stream straight through.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	status    int
+	streaming bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.streaming {
+		return
+	}
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) startStreaming() bool {
+	if !w.streaming && w.body.Len() == 0 && !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	return w.streaming
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if w.startStreaming() {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	if w.startStreaming() {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.streaming {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.streaming || w.body.Len() > 0
+}
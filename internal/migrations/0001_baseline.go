@@ -0,0 +1,332 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The baseline is the schema of the first release with versioned migrations,
+// frozen as private copies of the models so later model edits
+// cannot change what it creates. Running AutoMigrate on these copies also
+// upgrades databases created by older builds, which only ever used
+// AutoMigrate. Schema changes go into new migrations, never into this file.
+// Down drops every table, users included, so it only runs when forced.
+func init() {
+	register(Migration{
+		Version:     1,
+		Name:        "baseline",
+		Destructive: true,
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineTables()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := baselineTables()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+func baselineTables() []interface{} {
+	return []interface{}{
+		&baselineUser{},
+		&baselineRotation{},
+		&baselinePressCategory{},
+		&baselinePressNews{},
+		&baselinePressLikeRecord{},
+		&baselineNotice{},
+		&baselineFriendlyNeighbor{},
+		&baselineFNComment{},
+		&baselineActivity{},
+		&baselineActivityCategory{},
+		&baselineRegistration{},
+		&baselineComment{},
+		&baselineCommentLikeRecord{},
+		&baselineGreenDataCard{},
+		&baselineGreenQuestion{},
+		&baselineGreenPaper{},
+		&baselineGreenPaperAnswer{},
+		&baselineGreenDataSeries{},
+		&baselineRefreshToken{},
+		&baselineRevokedToken{},
+		&baselineSMSCode{},
+	}
+}
+
+type baselineUser struct {
+	gorm.Model
+	UserName     string  `gorm:"column:user_name"`
+	NickName     string  `gorm:"column:nick_name"`
+	PassWord     string  `gorm:"column:pass_word"`
+	Phone        string  `gorm:"column:phone"`
+	Email        string  `gorm:"column:email"`
+	Sex          string  `gorm:"column:sex"`
+	Avatar       string  `gorm:"column:avatar"`
+	Status       string  `gorm:"column:status"`
+	DelFlag      string  `gorm:"column:del_flag"`
+	LoginDate    string  `gorm:"column:login_date"`
+	IP           string  `gorm:"column:ip"`
+	IDCard       string  `gorm:"column:id_card"`
+	Address      string  `gorm:"column:address"`
+	Introduction string  `gorm:"column:introduction"`
+	Balance      float64 `gorm:"column:balance"`
+	Score        int     `gorm:"column:score"`
+	Role         string  `gorm:"column:role;default:resident"`
+	TokenVersion int     `gorm:"column:token_version;default:0"`
+
+	FailedLoginCount int        `gorm:"column:failed_login_count;default:0"`
+	LockedUntil      *time.Time `gorm:"column:locked_until"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineRotation struct {
+	gorm.Model
+	Title   string `gorm:"column:title"`
+	PicPath string `gorm:"column:pic_path"`
+	Link    string `gorm:"column:link"`
+	Type    int    `gorm:"column:type"`
+	Status  string `gorm:"column:status"`
+}
+
+func (baselineRotation) TableName() string { return "rotations" }
+
+type baselinePressCategory struct {
+	gorm.Model
+	Name     string `gorm:"column:name"`
+	ParentId int    `gorm:"column:parent_id"`
+	Sort     int    `gorm:"column:sort"`
+	Status   string `gorm:"column:status"`
+}
+
+func (baselinePressCategory) TableName() string { return "press_categories" }
+
+type baselinePressNews struct {
+	gorm.Model
+	Title       string    `gorm:"column:title"`
+	SubTitle    string    `gorm:"column:sub_title"`
+	Content     string    `gorm:"column:content;type:text"`
+	ImageUrls   string    `gorm:"column:image_urls"`
+	CategoryId  int       `gorm:"column:category_id"`
+	Author      string    `gorm:"column:author"`
+	Source      string    `gorm:"column:source"`
+	ViewCount   int       `gorm:"column:view_count"`
+	LikeNum     int       `gorm:"column:like_num"`
+	CommentNum  int       `gorm:"column:comment_num"`
+	Type        string    `gorm:"column:type"`
+	Top         string    `gorm:"column:top"`
+	Hot         string    `gorm:"column:hot"`
+	Tags        string    `gorm:"column:tags"`
+	Status      string    `gorm:"column:status"`
+	PublishDate time.Time `gorm:"column:publish_date"`
+}
+
+func (baselinePressNews) TableName() string { return "press_news" }
+
+type baselinePressLikeRecord struct {
+	gorm.Model
+	NewsId int `gorm:"column:news_id;index:idx_press_like_user_news,unique"`
+	UserId int `gorm:"column:user_id;index:idx_press_like_user_news,unique"`
+}
+
+func (baselinePressLikeRecord) TableName() string { return "press_like_records" }
+
+type baselineNotice struct {
+	gorm.Model
+	Title         string    `gorm:"column:title"`
+	NoticeStatus  string    `gorm:"column:notice_status"`
+	NoticeContent string    `gorm:"column:notice_content;type:text"`
+	PublishDate   time.Time `gorm:"column:publish_date"`
+	CreateBy      string    `gorm:"column:create_by"`
+}
+
+func (baselineNotice) TableName() string { return "notices" }
+
+type baselineFriendlyNeighbor struct {
+	gorm.Model
+	UserId     int    `gorm:"column:user_id"`
+	NickName   string `gorm:"column:nick_name"`
+	UserImgUrl string `gorm:"column:user_img_url"`
+	Content    string `gorm:"column:content;type:text"`
+	ImgUrl     string `gorm:"column:img_url"`
+	CommentNum int    `gorm:"column:comment_num"`
+	LikeNum    int    `gorm:"column:like_num"`
+	CreateTime string `gorm:"column:create_time"`
+}
+
+func (baselineFriendlyNeighbor) TableName() string { return "friendly_neighbors" }
+
+type baselineFNComment struct {
+	gorm.Model
+	NeighborId int    `gorm:"column:neighbor_id"`
+	UserId     int    `gorm:"column:user_id"`
+	NickName   string `gorm:"column:nick_name"`
+	UserImgUrl string `gorm:"column:user_img_url"`
+	Content    string `gorm:"column:content;type:text"`
+	CreateTime string `gorm:"column:create_time"`
+}
+
+func (baselineFNComment) TableName() string { return "fn_comments" }
+
+type baselineActivity struct {
+	gorm.Model
+	Title        string    `gorm:"column:title"`
+	Content      string    `gorm:"column:content;type:text"`
+	PicPath      string    `gorm:"column:pic_path"`
+	CategoryId   int       `gorm:"column:category_id"`
+	StartDate    time.Time `gorm:"column:start_date"`
+	EndDate      time.Time `gorm:"column:end_date"`
+	Address      string    `gorm:"column:address"`
+	TotalCount   int       `gorm:"column:total_count"`
+	CurrentCount int       `gorm:"column:current_count"`
+	IsTop        string    `gorm:"column:is_top"`
+	Status       string    `gorm:"column:status"`
+	CreateBy     string    `gorm:"column:create_by"`
+	CreateTime   string    `gorm:"column:create_time"`
+}
+
+func (baselineActivity) TableName() string { return "activities" }
+
+type baselineActivityCategory struct {
+	gorm.Model
+	Name   string `gorm:"column:name"`
+	Status string `gorm:"column:status"`
+}
+
+func (baselineActivityCategory) TableName() string { return "activity_categories" }
+
+type baselineRegistration struct {
+	gorm.Model
+	UserId        int    `gorm:"column:user_id"`
+	UserName      string `gorm:"column:user_name"`
+	NickName      string `gorm:"column:nick_name"`
+	Phone         string `gorm:"column:phone"`
+	ActivityId    int    `gorm:"column:activity_id"`
+	Status        string `gorm:"column:status"`
+	CheckinStatus string `gorm:"column:checkin_status"`
+	Comment       string `gorm:"column:comment"`
+	Star          int    `gorm:"column:star"`
+	CreateTime    string `gorm:"column:create_time"`
+}
+
+func (baselineRegistration) TableName() string { return "registrations" }
+
+type baselineComment struct {
+	gorm.Model
+	Type       string `gorm:"column:type"`
+	Sid        int    `gorm:"column:sid"`
+	Content    string `gorm:"column:content;type:text"`
+	LikeNum    int    `gorm:"column:like_num"`
+	ReplyNum   int    `gorm:"column:reply_num"`
+	UserId     int    `gorm:"column:user_id"`
+	NickName   string `gorm:"column:nick_name"`
+	UserImgUrl string `gorm:"column:user_img_url"`
+	CreateTime string `gorm:"column:create_time"`
+}
+
+func (baselineComment) TableName() string { return "comments" }
+
+type baselineCommentLikeRecord struct {
+	gorm.Model
+	CommentId int `gorm:"column:comment_id;index:idx_comment_like_user_comment,unique"`
+	UserId    int `gorm:"column:user_id;index:idx_comment_like_user_comment,unique"`
+}
+
+func (baselineCommentLikeRecord) TableName() string { return "comment_like_records" }
+
+type baselineGreenDataCard struct {
+	ID    uint   `gorm:"primaryKey"`
+	Icon  string `gorm:"column:icon"`
+	Title string `gorm:"column:title"`
+	Num   string `gorm:"column:num"`
+	Unit  string `gorm:"column:unit"`
+	Trend string `gorm:"column:trend"`
+	Sort  int    `gorm:"column:sort"`
+}
+
+func (baselineGreenDataCard) TableName() string { return "green_data_cards" }
+
+type baselineGreenQuestion struct {
+	ID           uint   `gorm:"primaryKey"`
+	QuestionType string `gorm:"column:question_type;size:8;index:idx_green_question_type_level"`
+	Level        string `gorm:"column:level;size:8;index:idx_green_question_type_level"`
+	Question     string `gorm:"column:question;type:text"`
+	OptionA      string `gorm:"column:option_a"`
+	OptionB      string `gorm:"column:option_b"`
+	OptionC      string `gorm:"column:option_c"`
+	OptionD      string `gorm:"column:option_d"`
+	OptionE      string `gorm:"column:option_e"`
+	OptionF      string `gorm:"column:option_f"`
+	Answer       string `gorm:"column:answer"`
+	Score        int    `gorm:"column:score"`
+	Status       string `gorm:"column:status"`
+}
+
+func (baselineGreenQuestion) TableName() string { return "green_questions" }
+
+type baselineGreenPaper struct {
+	gorm.Model
+	UserId   int    `gorm:"column:user_id;index"`
+	Score    string `gorm:"column:score"`
+	RawInput string `gorm:"column:raw_input;type:text"`
+}
+
+func (baselineGreenPaper) TableName() string { return "green_papers" }
+
+type baselineGreenPaperAnswer struct {
+	gorm.Model
+	PaperId     uint   `gorm:"column:paper_id;index"`
+	QuestionId  uint   `gorm:"column:question_id;index"`
+	UserAnswer  string `gorm:"column:user_answer"`
+	RightAnswer string `gorm:"column:right_answer"`
+	IsCorrect   string `gorm:"column:is_correct"`
+}
+
+func (baselineGreenPaperAnswer) TableName() string { return "green_paper_answers" }
+
+type baselineGreenDataSeries struct {
+	ID      uint   `gorm:"primaryKey"`
+	ListKey string `gorm:"column:list_key;size:64;index:idx_green_data_series_key_sort"`
+	Name    string `gorm:"column:name;size:128"`
+	Data    string `gorm:"column:data;type:text"`
+	Sort    int    `gorm:"column:sort;index:idx_green_data_series_key_sort"`
+}
+
+func (baselineGreenDataSeries) TableName() string { return "green_data_series" }
+
+type baselineRefreshToken struct {
+	gorm.Model
+	UserId    int        `gorm:"column:user_id;index"`
+	TokenHash string     `gorm:"column:token_hash;size:64;uniqueIndex"`
+	FamilyId  string     `gorm:"column:family_id;size:64;index"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+}
+
+func (baselineRefreshToken) TableName() string { return "refresh_tokens" }
+
+type baselineRevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	Jti       string    `gorm:"column:jti;size:64;uniqueIndex"`
+	UserId    int       `gorm:"column:user_id;index"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+	CreatedAt time.Time
+}
+
+func (baselineRevokedToken) TableName() string { return "revoked_tokens" }
+
+type baselineSMSCode struct {
+	ID        uint      `gorm:"primaryKey"`
+	CodeKey   string    `gorm:"column:code_key;size:64;uniqueIndex"`
+	Code      string    `gorm:"column:code"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+	Attempts  int       `gorm:"column:attempts"`
+}
+
+func (baselineSMSCode) TableName() string { return "sms_codes" }
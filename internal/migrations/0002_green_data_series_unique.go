@@ -0,0 +1,136 @@
+package migrations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Older builds rewrote green_data_series on every boot to make list_key and
+// name unique; this runs that cleanup once and then enforces it with indexes.
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "green_data_series_unique_keys",
+		Up: func(tx *gorm.DB) error {
+			if err := normalizeGreenDataSeries(tx); err != nil {
+				return err
+			}
+			if err := createIndexIfMissing(tx, "idx_green_data_series_list_key_unique", "list_key"); err != nil {
+				return err
+			}
+			return createIndexIfMissing(tx, "idx_green_data_series_name_unique", "name")
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, name := range []string{"idx_green_data_series_list_key_unique", "idx_green_data_series_name_unique"} {
+				if tx.Migrator().HasIndex("green_data_series", name) {
+					if err := tx.Migrator().DropIndex("green_data_series", name); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	})
+}
+
+func createIndexIfMissing(tx *gorm.DB, name, column string) error {
+	if tx.Migrator().HasIndex("green_data_series", name) {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON green_data_series (%s)", name, column)).Error
+}
+
+func normalizeGreenDataSeries(tx *gorm.DB) error {
+	var rows []struct {
+		ID      uint
+		ListKey string
+		Name    string
+	}
+	if err := tx.Table("green_data_series").Select("id, list_key, name").Order("id asc").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	usedListKeys := make(map[string]struct{}, len(rows))
+	usedListNums := make(map[int]struct{}, len(rows))
+	usedNames := make(map[string]struct{}, len(rows))
+
+	claimListNum := func(start int) int {
+		if start < 1 {
+			start = 1
+		}
+		for n := start; ; n++ {
+			if _, exists := usedListNums[n]; !exists {
+				usedListNums[n] = struct{}{}
+				return n
+			}
+		}
+	}
+
+	parseListNum := func(listKey string) (int, bool) {
+		if !strings.HasPrefix(listKey, "list_") {
+			return 0, false
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(listKey, "list_"))
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return n, true
+	}
+
+	for _, row := range rows {
+		rawListKey := strings.TrimSpace(row.ListKey)
+		nextListNum := 0
+		if n, ok := parseListNum(rawListKey); ok {
+			if _, exists := usedListKeys[rawListKey]; !exists {
+				nextListNum = claimListNum(n)
+			} else {
+				nextListNum = claimListNum(n + 1)
+			}
+		} else {
+			nextListNum = claimListNum(1)
+		}
+		nextListKey := fmt.Sprintf("list_%d", nextListNum)
+
+		nextName := strings.TrimSpace(row.Name)
+		if nextName == "" {
+			nextName = nextListKey
+		}
+		if _, exists := usedNames[nextName]; exists {
+			if _, canUseListKey := usedNames[nextListKey]; !canUseListKey {
+				nextName = nextListKey
+			} else {
+				base := nextName
+				i := 2
+				for {
+					candidate := fmt.Sprintf("%s_%d", base, i)
+					if _, used := usedNames[candidate]; !used {
+						nextName = candidate
+						break
+					}
+					i++
+				}
+			}
+		}
+
+		updates := map[string]interface{}{}
+		if row.ListKey != nextListKey {
+			updates["list_key"] = nextListKey
+		}
+		if row.Name != nextName {
+			updates["name"] = nextName
+		}
+		if len(updates) > 0 {
+			if err := tx.Table("green_data_series").Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		usedListKeys[nextListKey] = struct{}{}
+		usedNames[nextName] = struct{}{}
+	}
+
+	return nil
+}
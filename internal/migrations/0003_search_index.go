@@ -0,0 +1,156 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchSource describes how one table feeds the search_index FTS5 table.
+// Index rows use rowid = id*4 + code so triggers can replace a row without
+// scanning the index.
+type searchSource struct {
+	table string
+	kind  string
+	code  int
+	title string // SQL expressions; {r} stands for the row alias
+	body  string
+	date  string
+	watch string // columns whose update re-indexes the row
+}
+
+var searchSources = []searchSource{
+	{
+		table: "press_news", kind: "news", code: 0,
+		title: "{r}.title",
+		body:  "coalesce({r}.sub_title, '') || ' ' || coalesce({r}.content, '')",
+		date:  "{r}.publish_date",
+		watch: "title, sub_title, content, publish_date, deleted_at",
+	},
+	{
+		table: "notices", kind: "notice", code: 1,
+		title: "{r}.title",
+		body:  "coalesce({r}.notice_content, '')",
+		date:  "{r}.publish_date",
+		watch: "title, notice_content, publish_date, deleted_at",
+	},
+	{
+		table: "activities", kind: "activity", code: 2,
+		title: "{r}.title",
+		body:  "coalesce({r}.content, '') || ' ' || coalesce({r}.address, '')",
+		date:  "{r}.start_date",
+		watch: "title, content, address, start_date, deleted_at",
+	},
+	{
+		table: "friendly_neighbors", kind: "neighbor", code: 3,
+		title: "{r}.nick_name",
+		body:  "coalesce({r}.content, '')",
+		date:  "{r}.created_at",
+		watch: "nick_name, content, deleted_at",
+	},
+}
+
+// ErrSearchIndexUnsupported is returned when the database has a search index
+// that the running binary cannot open.
+var ErrSearchIndexUnsupported = errors.New("database has a full-text search index but this binary was built without -tags sqlite_fts5")
+
+// FTS5Available reports whether db is SQLite with the FTS5 module compiled in.
+func FTS5Available(db *gorm.DB) bool {
+	if db.Dialector.Name() != "sqlite" {
+		return false
+	}
+	var used int
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error; err != nil {
+		return false
+	}
+	return used == 1
+}
+
+// HasSearchIndex reports whether the search_index table exists.
+func HasSearchIndex(db *gorm.DB) bool {
+	if db.Dialector.Name() != "sqlite" {
+		return false
+	}
+	var n int64
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE name = 'search_index'").Scan(&n)
+	return n > 0
+}
+
+// CheckSearchIndex refuses to start a build without FTS5 on a database whose
+// triggers write to search_index, since every write to the indexed tables
+// would fail.
+func CheckSearchIndex(db *gorm.DB) error {
+	if HasSearchIndex(db) && !FTS5Available(db) {
+		return ErrSearchIndexUnsupported
+	}
+	return nil
+}
+
+// The index is only built on SQLite with FTS5; elsewhere the search endpoint
+// falls back to LIKE queries. Other builds leave this migration unrecorded,
+// so the first FTS5 build to open the database builds the index. Databases
+// where an older build recorded it without building anything count as
+// missing the index and get it too.
+func init() {
+	register(Migration{
+		Version:   3,
+		Name:      "search_index",
+		Supported: FTS5Available,
+		Missing:   func(db *gorm.DB) bool { return !HasSearchIndex(db) },
+		Up: func(tx *gorm.DB) error {
+			stmts := []string{`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+				kind UNINDEXED, ref_id UNINDEXED, date UNINDEXED, title, body, tokenize = 'trigram')`}
+			for _, s := range searchSources {
+				stmts = append(stmts, searchTriggers(s)...)
+				stmts = append(stmts, fmt.Sprintf(
+					"INSERT INTO search_index (rowid, kind, ref_id, date, title, body) SELECT %s FROM %s WHERE deleted_at IS NULL",
+					searchColumns(s, s.table), s.table))
+			}
+			for _, stmt := range stmts {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if !HasSearchIndex(tx) {
+				return nil
+			}
+			if !FTS5Available(tx) {
+				return ErrSearchIndexUnsupported
+			}
+			for _, s := range searchSources {
+				for _, suffix := range []string{"ai", "au", "ad"} {
+					if err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS search_%s_%s", s.table, suffix)).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return tx.Exec("DROP TABLE search_index").Error
+		},
+	})
+}
+
+func searchColumns(s searchSource, alias string) string {
+	cols := fmt.Sprintf("{r}.id * 4 + %d, '%s', {r}.id, coalesce(strftime('%%Y-%%m-%%d %%H:%%M:%%S', %s), ''), coalesce(%s, ''), %s",
+		s.code, s.kind, s.date, s.title, s.body)
+	return strings.ReplaceAll(cols, "{r}", alias)
+}
+
+func searchTriggers(s searchSource) []string {
+	insert := fmt.Sprintf(
+		"INSERT INTO search_index (rowid, kind, ref_id, date, title, body) SELECT %s WHERE new.deleted_at IS NULL;",
+		searchColumns(s, "new"))
+	remove := fmt.Sprintf("DELETE FROM search_index WHERE rowid = old.id * 4 + %d;", s.code)
+	return []string{
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS search_%s_ai AFTER INSERT ON %s BEGIN %s END",
+			s.table, s.table, insert),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS search_%s_au AFTER UPDATE OF %s ON %s BEGIN %s %s END",
+			s.table, s.watch, s.table, remove, insert),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS search_%s_ad AFTER DELETE ON %s BEGIN %s END",
+			s.table, s.table, remove),
+	}
+}
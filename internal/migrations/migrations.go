@@ -0,0 +1,219 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one reviewable schema step. Up and Down run inside a
+// transaction; Down must undo exactly what Up did. Destructive marks a Down
+// that deletes data, which only runs when forced.
+type Migration struct {
+	Version     int
+	Name        string
+	Destructive bool
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error
+
+	// Supported, when set, reports whether db can run the migration at all,
+	// e.g. only SQLite builds with FTS5. Unsupported migrations are neither
+	// pending nor recorded; they run once a build that supports them opens
+	// the database, so they must not be needed by later migrations.
+	Supported func(db *gorm.DB) bool
+	// Missing, when set, reports that what a recorded migration creates is
+	// absent, which makes it pending again.
+	Missing func(db *gorm.DB) bool
+}
+
+func (m Migration) supported(db *gorm.DB) bool {
+	return m.Supported == nil || m.Supported(db)
+}
+
+func (m Migration) pending(db *gorm.DB, done map[int]schemaMigration) bool {
+	if !m.supported(db) {
+		return false
+	}
+	_, ok := done[m.Version]
+	return !ok || (m.Missing != nil && m.Missing(db))
+}
+
+// ErrDestructiveDown is returned when rolling back a destructive migration
+// without force.
+var ErrDestructiveDown = errors.New("rolling back this migration drops tables and their data; rerun with -force")
+
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version     int
+	Name        string
+	Applied     bool
+	AppliedAt   *time.Time
+	Unsupported bool
+}
+
+var registry []Migration
+
+func register(m Migration) {
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool { return registry[i].Version < registry[j].Version })
+}
+
+func All() []Migration {
+	return append([]Migration(nil), registry...)
+}
+
+func Latest() int {
+	if len(registry) == 0 {
+		return 0
+	}
+	return registry[len(registry)-1].Version
+}
+
+// LatestSupported is the highest version db can reach with this build;
+// migrations the build or database cannot run are left out.
+func LatestSupported(db *gorm.DB) int {
+	latest := 0
+	for _, m := range registry {
+		if m.supported(db) {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.AutoMigrate(&schemaMigration{})
+}
+
+func applied(db *gorm.DB) (map[int]schemaMigration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := db.Order("version asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int]schemaMigration, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+// Current returns the highest applied version, or 0 for an empty database.
+func Current(db *gorm.DB) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for v := range done {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
+
+func List(db *gorm.DB) ([]Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(registry))
+	for _, m := range registry {
+		st := Status{Version: m.Version, Name: m.Name, Unsupported: !m.supported(db)}
+		if row, ok := done[m.Version]; ok && (m.Missing == nil || !m.Missing(db)) {
+			at := row.AppliedAt
+			st.Applied = true
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func Pending(db *gorm.DB) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, m := range registry {
+		if m.pending(db, done) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// Up applies pending migrations up to and including target (0 means latest)
+// and returns the ones it ran.
+func Up(db *gorm.DB, target int) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, m := range pending {
+		if target > 0 && m.Version > target {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			// Save, since a migration whose objects went missing is rerun
+			// over its existing record.
+			return tx.Save(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s) up: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// Down rolls back the most recently applied steps migrations and returns the
+// ones it reverted. It stops before a destructive migration unless force is
+// set.
+func Down(db *gorm.DB, steps int, force bool) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var reverted []Migration
+	for i := len(registry) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := registry[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if m.Destructive && !force {
+			return reverted, fmt.Errorf("migration %d (%s) down: %w", m.Version, m.Name, ErrDestructiveDown)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d (%s) down: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
@@ -0,0 +1,74 @@
+package migrations_test
+
+import (
+	"digital-community/internal/migrations"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func migratedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a separate database.
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := migrations.Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestBaselineDownNeedsForce(t *testing.T) {
+	db := migratedDB(t)
+
+	list, err := migrations.List(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := 0
+	for _, st := range list {
+		if st.Applied {
+			applied++
+		}
+	}
+	reverted, err := migrations.Down(db, migrations.Latest(), false)
+	if !errors.Is(err, migrations.ErrDestructiveDown) || len(reverted) != applied-1 {
+		t.Fatalf("down without force: reverted %d, err %v", len(reverted), err)
+	}
+	if !db.Migrator().HasTable("users") {
+		t.Fatal("users dropped without force")
+	}
+
+	if _, err := migrations.Down(db, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Fatal("forced down left users in place")
+	}
+}
+
+// Without FTS5 the search index migration stays unrecorded instead of being
+// marked applied, so a later FTS5 build still builds the index.
+func TestUnsupportedMigrationIsNotRecorded(t *testing.T) {
+	db := migratedDB(t)
+	pending, err := migrations.Pending(db)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending after up: %v %v", pending, err)
+	}
+
+	list, err := migrations.List(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range list {
+		if st.Name == "search_index" && st.Applied != migrations.FTS5Available(db) {
+			t.Fatalf("search_index applied=%v with FTS5=%v", st.Applied, migrations.FTS5Available(db))
+		}
+	}
+}
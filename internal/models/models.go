@@ -6,6 +6,31 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleResident = "resident"
+	RoleEditor   = "editor"
+	RoleAdmin    = "admin"
+)
+
+func IsValidRole(role string) bool {
+	return role == RoleResident || role == RoleEditor || role == RoleAdmin
+}
+
+func NormalizeRole(role string) string {
+	if IsValidRole(role) {
+		return role
+	}
+	return RoleResident
+}
+
+const (
+	UserStatusNormal   = "0"
+	UserStatusDisabled = "1"
+
+	DelFlagExists  = "0"
+	DelFlagDeleted = "2"
+)
+
 type User struct {
 	gorm.Model
 	UserName     string  `json:"userName" gorm:"column:user_name"`
@@ -24,6 +49,15 @@ type User struct {
 	Introduction string  `json:"introduction" gorm:"column:introduction"`
 	Balance      float64 `json:"balance" gorm:"column:balance"`
 	Score        int     `json:"score" gorm:"column:score"`
+	Role         string  `json:"role" gorm:"column:role;default:resident"`
+	TokenVersion int     `json:"-" gorm:"column:token_version;default:0"`
+
+	FailedLoginCount int        `json:"failedLoginCount" gorm:"column:failed_login_count;default:0"`
+	LockedUntil      *time.Time `json:"lockedUntil" gorm:"column:locked_until"`
+}
+
+func (u User) IsActive() bool {
+	return u.Status != UserStatusDisabled && (u.DelFlag == "" || u.DelFlag == DelFlagExists)
 }
 
 type Rotation struct {
@@ -168,8 +202,8 @@ type GreenDataCard struct {
 
 type GreenQuestion struct {
 	ID           uint   `json:"id" gorm:"primaryKey"`
-	QuestionType string `json:"questionType" gorm:"column:question_type;index:idx_green_question_type_level"`
-	Level        string `json:"level" gorm:"column:level;index:idx_green_question_type_level"`
+	QuestionType string `json:"questionType" gorm:"column:question_type;size:8;index:idx_green_question_type_level"`
+	Level        string `json:"level" gorm:"column:level;size:8;index:idx_green_question_type_level"`
 	Question     string `json:"question" gorm:"column:question;type:text"`
 	OptionA      string `json:"optionA" gorm:"column:option_a"`
 	OptionB      string `json:"optionB" gorm:"column:option_b"`
@@ -200,8 +234,34 @@ type GreenPaperAnswer struct {
 
 type GreenDataSeries struct {
 	ID      uint   `json:"id" gorm:"primaryKey"`
-	ListKey string `json:"listKey" gorm:"column:list_key;index:idx_green_data_series_key_sort"`
-	Name    string `json:"name" gorm:"column:name"`
+	ListKey string `json:"listKey" gorm:"column:list_key;size:64;index:idx_green_data_series_key_sort"`
+	Name    string `json:"name" gorm:"column:name;size:128"`
 	Data    string `json:"data" gorm:"column:data;type:text"`
 	Sort    int    `json:"sort" gorm:"column:sort;index:idx_green_data_series_key_sort"`
 }
+
+type RefreshToken struct {
+	gorm.Model
+	UserId    int        `json:"userId" gorm:"column:user_id;index"`
+	TokenHash string     `json:"-" gorm:"column:token_hash;size:64;uniqueIndex"`
+	FamilyId  string     `json:"familyId" gorm:"column:family_id;size:64;index"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"column:expires_at"`
+	UsedAt    *time.Time `json:"usedAt" gorm:"column:used_at"`
+	RevokedAt *time.Time `json:"revokedAt" gorm:"column:revoked_at"`
+}
+
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Jti       string    `json:"jti" gorm:"column:jti;size:64;uniqueIndex"`
+	UserId    int       `json:"userId" gorm:"column:user_id;index"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"column:expires_at;index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SMSCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CodeKey   string    `json:"codeKey" gorm:"column:code_key;size:64;uniqueIndex"`
+	Code      string    `json:"-" gorm:"column:code"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"column:expires_at;index"`
+	Attempts  int       `json:"attempts" gorm:"column:attempts"`
+}
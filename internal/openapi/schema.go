@@ -0,0 +1,139 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Registry builds schemas for Go values. Named struct types are stored once
+// under components/schemas and referenced from everywhere else.
+type Registry struct {
+	Schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{Schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// SchemaOf returns the schema of v's type. A nil v means any value.
+func (r *Registry) SchemaOf(v any) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return r.schema(reflect.TypeOf(v))
+}
+
+func (r *Registry) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := r.schema(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+		return r.named(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (r *Registry) named(t reflect.Type) *Schema {
+	if name, ok := r.names[t]; ok {
+		return Ref(name)
+	}
+	name := t.Name()
+	if _, taken := r.Schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	r.names[t] = name
+	// Reserve the slot first so self-referencing types terminate.
+	r.Schemas[name] = &Schema{}
+	*r.Schemas[name] = *r.object(t)
+	return Ref(name)
+}
+
+func (r *Registry) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	r.addFields(s, t)
+	return s
+}
+
+func (r *Registry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = r.schema(f.Type)
+		if hasRule(f.Tag.Get("binding"), "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
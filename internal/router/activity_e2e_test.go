@@ -0,0 +1,125 @@
+package router_test
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newActivity(title string, totalCount int, isTop string) map[string]any {
+	return map[string]any{
+		"title":      title,
+		"content":    "活动内容",
+		"picPath":    "/profile/upload/image/act.png",
+		"categoryId": 1,
+		"startDate":  "2024-05-04T09:00:00Z",
+		"endDate":    "2024-05-04T11:30:00Z",
+		"address":    "社区活动中心",
+		"totalCount": totalCount,
+		"isTop":      isTop,
+		"createBy":   "居委会",
+	}
+}
+
+func TestActivityCRUDAndListing(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+
+	id := s.ok(s.call("POST", "/activity", editor, newActivity("植树活动", 30, "1"))).id()
+	s.ok(s.call("POST", "/activity", editor, newActivity("读书会", 10, "0")))
+	s.expect(s.call("POST", "/activity", editor, map[string]any{"title": "缺分类", "content": "x"}), 500, "参数错误")
+
+	detail := s.ok(s.call("GET", fmt.Sprintf("/activity/%d", id), "", nil))
+	assertGolden(t, "activity_detail", detail.body)
+
+	assertGolden(t, "activity_list", s.ok(s.call("GET", "/activity/list", "", nil)).body)
+	if top := s.ok(s.call("GET", "/activity/topList", "", nil)); top.total() != 1 {
+		t.Fatalf("want 1 pinned activity, got %d", top.total())
+	}
+	if found := s.ok(s.call("POST", "/activity/search", "", map[string]string{"words": "读书"})); found.total() != 1 {
+		t.Fatalf("search: %v", found.body)
+	}
+	if byCategory := s.ok(s.call("GET", "/activity/category/list/1", "", nil)); byCategory.total() != 2 {
+		t.Fatalf("category list: %v", byCategory.body)
+	}
+
+	s.ok(s.call("PUT", fmt.Sprintf("/activity/%d", id), editor, map[string]any{"isTop": "0", "address": "东门广场"}))
+	data := s.ok(s.call("GET", fmt.Sprintf("/activity/%d", id), "", nil)).data()
+	if data["isTop"] != "0" || data["title"] != "植树活动" {
+		t.Fatalf("update: %v", data)
+	}
+	// With nothing pinned the top list falls back to every activity.
+	if top := s.ok(s.call("GET", "/activity/topList", "", nil)); top.total() != 2 {
+		t.Fatalf("top list fallback: %v", top.body)
+	}
+
+	s.ok(s.call("DELETE", fmt.Sprintf("/activity/%d", id), editor, nil))
+	s.expect(s.call("GET", fmt.Sprintf("/activity/%d", id), "", nil), 404, "活动不存在")
+}
+
+func TestActivityRegistrationCapacity(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+	neighbor := s.login(neighborUser)
+
+	id := s.ok(s.call("POST", "/activity", editor, newActivity("限额活动", 1, "0"))).id()
+	register := map[string]int{"activityId": id}
+
+	s.ok(s.call("POST", "/registration", resident, register))
+	s.expect(s.call("POST", "/registration", resident, register), 500, "已报名")
+	s.expect(s.call("POST", "/registration", neighbor, register), 500, "活动报名人数已满")
+	s.expect(s.call("POST", "/registration", neighbor, map[string]int{"activityId": 9999}), 404, "活动不存在")
+
+	data := s.ok(s.call("GET", fmt.Sprintf("/activity/%d", id), "", nil)).data()
+	if data["signUpNum"].(float64) != 1 {
+		t.Fatalf("signUpNum: %v", data)
+	}
+
+	// Unlimited activities (totalCount 0) never fill up.
+	open := s.ok(s.call("POST", "/activity", editor, newActivity("不限人数", 0, "0"))).id()
+	s.ok(s.call("POST", "/registration", resident, map[string]int{"activityId": open}))
+	s.ok(s.call("POST", "/registration", neighbor, map[string]int{"activityId": open}))
+
+	s.expect(s.call("PUT", fmt.Sprintf("/checkin/%d", id), neighbor, nil), 500, "未找到报名记录")
+	s.ok(s.call("PUT", fmt.Sprintf("/checkin/%d", id), resident, nil))
+	s.expect(s.call("PUT", fmt.Sprintf("/registration/comment/%d", id), resident, map[string]any{"evaluate": "很好", "star": 6}), 500, "评分参数错误")
+	s.ok(s.call("PUT", fmt.Sprintf("/registration/comment/%d", id), resident, map[string]any{"evaluate": "很好", "star": 5}))
+
+	// Residents only see their own registrations.
+	if own := s.ok(s.call("GET", fmt.Sprintf("/registration/list?userId=%d", 4), resident, nil)); own.total() != 2 {
+		t.Fatalf("resident registrations: %v", own.body)
+	}
+	list := s.ok(s.call("GET", fmt.Sprintf("/registration/list?activityId=%d", id), editor, nil))
+	assertGolden(t, "registration_list", list.body)
+}
+
+func TestFriendlyNeighborCRUDAndComments(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	id := s.ok(s.call("POST", "/friendly_neighborhood", resident, map[string]any{
+		"content":    "谁家的猫走丢了？",
+		"imgUrl":     "/profile/upload/image/cat.png",
+		"userId":     3,
+		"nickName":   "居民甲",
+		"userImgUrl": "/profile/upload/image/avatar.png",
+	})).id()
+
+	s.ok(s.call("POST", "/friendly_neighborhood/add/comment", "", map[string]any{"neighborhoodId": id, "content": "在三号楼见过"}))
+
+	detail := s.ok(s.call("GET", fmt.Sprintf("/friendly_neighborhood/%d", id), "", nil))
+	assertGolden(t, "neighbor_detail", detail.body)
+	if list := s.ok(s.call("GET", "/friendly_neighborhood/list", "", nil)); list.total() != 1 {
+		t.Fatalf("neighbor list: %v", list.body)
+	}
+
+	// Authors and content managers may change a post; other residents may not.
+	s.expect(s.call("PUT", fmt.Sprintf("/friendly_neighborhood/%d", id), s.login(neighborUser), map[string]string{"content": "已找到"}), 403, "无权限")
+	s.expect(s.call("DELETE", fmt.Sprintf("/friendly_neighborhood/%d", id), s.login(neighborUser), nil), 403, "无权限")
+	s.ok(s.call("PUT", fmt.Sprintf("/friendly_neighborhood/%d", id), resident, map[string]string{"content": "已找到"}))
+	s.ok(s.call("PUT", fmt.Sprintf("/friendly_neighborhood/%d", id), editor, map[string]string{"content": "已找到失主"}))
+	s.ok(s.call("DELETE", fmt.Sprintf("/friendly_neighborhood/%d", id), resident, nil))
+	s.expect(s.call("GET", fmt.Sprintf("/friendly_neighborhood/%d", id), "", nil), 404, "记录不存在")
+	s.expect(s.call("DELETE", fmt.Sprintf("/friendly_neighborhood/%d", id), editor, nil), 404, "帖子不存在")
+}
@@ -0,0 +1,198 @@
+package router_test
+
+import (
+	"digital-community/internal/models"
+	"fmt"
+	"testing"
+)
+
+func TestLoginWithPassword(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": testPassword}))
+	assertGolden(t, "login", shapeOf(resp.body))
+
+	// The legacy passWord field from the interface document still works.
+	s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "passWord": testPassword}))
+
+	s.expect(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": "wrong"}), 500, "用户名或密码错误")
+	s.expect(s.call("POST", "/login", "", map[string]string{"userName": "nobody", "password": testPassword}), 500, "用户名或密码错误")
+	s.expect(s.call("POST", "/login", "", map[string]string{"password": testPassword}), 500, "参数错误")
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+
+	for i := 0; i < s.cfg.LoginMaxFailures; i++ {
+		resp := s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": "wrong"})
+		if resp.code() == 429 {
+			break
+		}
+	}
+	s.expect(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": testPassword}), 429, "")
+
+	admin := s.login(adminUser)
+	var user models.User
+	s.db.Where("user_name = ?", residentUser).First(&user)
+	s.ok(s.call("PUT", fmt.Sprintf("/user/%d/unlock", user.ID), admin, nil))
+	s.login(residentUser)
+}
+
+func TestPhoneLoginWithSMSCode(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect(s.call("GET", "/smsCode?phone=123", "", nil), 500, "手机号格式错误")
+	s.expect(s.call("GET", "/smsCode?phone=13900000000", "", nil), 500, "手机号未注册")
+
+	resp := s.ok(s.call("GET", "/smsCode?phone="+residentPhone, "", nil))
+	code, _ := resp.body["data"].(string)
+	if len(code) != 4 {
+		t.Fatalf("dev mode should return the code, got %v", resp.body)
+	}
+	s.expect(s.call("GET", "/smsCode?phone="+residentPhone, "", nil), 429, "")
+
+	s.expect(s.call("POST", "/phone/login", "", map[string]string{"phone": residentPhone, "smsCode": "0000"}), 500, "验证码错误或已过期")
+	resp = s.ok(s.call("POST", "/phone/login", "", map[string]string{"phone": residentPhone, "smsCode": code}))
+	if resp.body["token"] == "" || resp.body["refreshToken"] == "" {
+		t.Fatalf("missing tokens: %v", resp.body)
+	}
+	// Codes are single-use.
+	s.expect(s.call("POST", "/phone/login", "", map[string]string{"phone": residentPhone, "smsCode": code}), 500, "验证码错误或已过期")
+}
+
+func TestRegisterThenLogin(t *testing.T) {
+	s := newTestServer(t)
+	body := map[string]string{
+		"userName":    "newcomer",
+		"nickName":    "新居民",
+		"password":    "pass1234",
+		"phoneNumber": "13700000000",
+		"sex":         "1",
+	}
+	s.ok(s.call("POST", "/register", "", body))
+	s.expect(s.call("POST", "/register", "", body), 500, "用户名已存在")
+
+	body["userName"] = "newcomer2"
+	s.expect(s.call("POST", "/register", "", body), 500, "手机号已注册")
+	body["phoneNumber"] = "12345"
+	s.expect(s.call("POST", "/register", "", body), 500, "手机号格式错误")
+
+	token := s.ok(s.call("POST", "/login", "", map[string]string{"userName": "newcomer", "password": "pass1234"})).body["token"].(string)
+	info := s.ok(s.call("GET", "/user/getUserInfo", token, nil)).data()
+	if info["userName"] != "newcomer" || info["phoneNumber"] != "13700000000" || info["role"] != models.RoleResident {
+		t.Fatalf("unexpected user info %v", info)
+	}
+}
+
+func TestUserInfoAndProfileUpdate(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login(residentUser)
+
+	info := s.ok(s.call("GET", "/user/getUserInfo", token, nil)).data()
+	delete(info, "userId")
+	assertGolden(t, "user_info", info)
+
+	s.ok(s.call("PUT", "/user/updateUserInfo", token, map[string]string{"nickName": "改名", "phoneNumber": residentPhone, "sex": "1", "email": "a@example.com"}))
+	info = s.ok(s.call("GET", "/user/getUserInfo", token, nil)).data()
+	if info["nickName"] != "改名" || info["email"] != "a@example.com" {
+		t.Fatalf("profile not updated: %v", info)
+	}
+
+	stolen := s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": testPassword}))
+	s.expect(s.call("PUT", "/user/resetPwd", token, map[string]string{"oldPassword": "wrong", "newPassword": "newpass1"}), 500, "原密码错误")
+	s.ok(s.call("PUT", "/user/resetPwd", token, map[string]string{"oldPassword": testPassword, "newPassword": "newpass1"}))
+	// Changing the password signs out every session.
+	s.expect(s.call("GET", "/user/getUserInfo", stolen.body["token"].(string), nil), 401, "")
+	s.expect(s.call("POST", "/token/refresh", "", map[string]string{"refreshToken": stolen.body["refreshToken"].(string)}), 401, "")
+	s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": "newpass1"}))
+}
+
+func TestPasswordResetBySMS(t *testing.T) {
+	s := newTestServer(t)
+	old := s.login(residentUser)
+
+	code := s.ok(s.call("GET", "/password/smsCode?phone="+residentPhone, "", nil)).body["data"].(string)
+	s.expect(s.call("POST", "/password/verify", "", map[string]string{"phone": residentPhone, "smsCode": "0000"}), 500, "验证码错误或已过期")
+	data := s.ok(s.call("POST", "/password/verify", "", map[string]string{"phone": residentPhone, "smsCode": code})).data()
+	resetToken := data["resetToken"].(string)
+
+	s.ok(s.call("POST", "/password/reset", "", map[string]string{"resetToken": resetToken, "newPassword": "reset123"}))
+	s.expect(s.call("POST", "/password/reset", "", map[string]string{"resetToken": resetToken, "newPassword": "again123"}), 500, "重置链接已失效，请重新验证")
+
+	// Existing sessions end with the reset.
+	s.expect(s.call("GET", "/user/getUserInfo", old, nil), 401, "")
+	s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": "reset123"}))
+}
+
+// Wrong reset codes count towards the login lockout, so the 4-digit code
+// cannot be brute-forced.
+func TestPasswordVerifyLockout(t *testing.T) {
+	s := newTestServer(t)
+	code := s.ok(s.call("GET", "/password/smsCode?phone="+residentPhone, "", nil)).body["data"].(string)
+
+	for i := 0; i < s.cfg.LoginMaxFailures; i++ {
+		resp := s.call("POST", "/password/verify", "", map[string]string{"phone": residentPhone, "smsCode": "0000"})
+		if resp.code() == 429 {
+			break
+		}
+	}
+	s.expect(s.call("POST", "/password/verify", "", map[string]string{"phone": residentPhone, "smsCode": code}), 429, "")
+	s.expect(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": testPassword}), 429, "")
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.ok(s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": testPassword}))
+	token := resp.body["token"].(string)
+	refresh := resp.body["refreshToken"].(string)
+
+	rotated := s.ok(s.call("POST", "/token/refresh", "", map[string]string{"refreshToken": refresh}))
+	// A refresh token works once; replaying it revokes the family.
+	s.expect(s.call("POST", "/token/refresh", "", map[string]string{"refreshToken": refresh}), 401, "")
+	s.expect(s.call("POST", "/token/refresh", "", map[string]string{"refreshToken": rotated.body["refreshToken"].(string)}), 401, "")
+
+	s.ok(s.send("POST", "/logout", token, map[string]string{}, "application/json"))
+	s.expect(s.call("GET", "/user/getUserInfo", token, nil), 401, "登录已失效")
+	s.expect(s.call("GET", "/user/getUserInfo", "", nil), 401, "未授权")
+}
+
+func TestRolePermissions(t *testing.T) {
+	s := newTestServer(t)
+	resident := s.login(residentUser)
+	editor := s.login(editorUser)
+	admin := s.login(adminUser)
+
+	news := map[string]any{"title": "t", "content": "c", "categoryId": 1}
+	s.expect(s.call("POST", "/press/news", resident, news), 403, "无权限")
+	s.ok(s.call("POST", "/press/news", editor, news))
+
+	s.expect(s.call("GET", "/user/list", editor, nil), 403, "无权限")
+	s.expect(s.call("GET", "/backup/list", editor, nil), 403, "无权限")
+	s.ok(s.call("GET", "/user/list", admin, nil))
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(adminUser)
+
+	id := s.ok(s.call("POST", "/user", admin, map[string]string{
+		"userName": "staff01", "password": testPassword, "phone": "13600000000", "role": models.RoleEditor,
+	})).id()
+	s.expect(s.call("POST", "/user", admin, map[string]string{"userName": "staff02", "password": "x", "phone": "13600000001", "role": "root"}), 500, "角色参数错误")
+
+	list := s.ok(s.call("GET", "/user/list?role=editor", admin, nil))
+	if list.total() != 2 {
+		t.Fatalf("want 2 editors, got %d", list.total())
+	}
+	assertGolden(t, "user_list", shapeOf(list.body))
+
+	staff := s.login("staff01")
+	s.ok(s.call("PUT", fmt.Sprintf("/user/%d", id), admin, map[string]string{"status": models.UserStatusDisabled}))
+	s.expect(s.call("GET", "/user/getUserInfo", staff, nil), 401, "账号已停用")
+	s.expect(s.call("POST", "/login", "", map[string]string{"userName": "staff01", "password": testPassword}), 500, "账号已停用")
+
+	s.ok(s.call("DELETE", fmt.Sprintf("/user/%d", id), admin, nil))
+	if n := count[models.User](t, s.db, "user_name = ?", "staff01"); n != 0 {
+		t.Fatalf("user still visible after delete")
+	}
+}
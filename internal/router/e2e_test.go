@@ -0,0 +1,284 @@
+package router_test
+
+import (
+	"bytes"
+	"digital-community/internal/auth"
+	"digital-community/internal/config"
+	"digital-community/internal/handlers"
+	"digital-community/internal/middleware"
+	"digital-community/internal/models"
+	"digital-community/internal/router"
+	"digital-community/internal/seed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// testNow is the clock every handler sees, so dates in responses are stable.
+var testNow = time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+const testPassword = "secret123"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	middleware.RegisterFieldNames()
+	log.SetOutput(io.Discard)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t      *testing.T
+	engine *gin.Engine
+	db     *gorm.DB
+	cfg    *config.Config
+	h      *handlers.Handlers
+	// strict sends every request in strict response mode.
+	strict bool
+}
+
+// Fixture accounts created by newTestServer.
+const (
+	adminUser    = "admin01"
+	editorUser   = "editor01"
+	residentUser = "resident01"
+	neighborUser = "resident02"
+
+	residentPhone = "13800000003"
+)
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.JWTSecret = "test-secret"
+	cfg.DBPath = filepath.Join(dir, "test.db")
+	cfg.UploadRoot = filepath.Join(dir, "upload")
+	cfg.BackupDir = filepath.Join(dir, "backups")
+	cfg.SMSDevMode = true
+	// Tokens are stamped with the pinned clock but verified against the real
+	// one, so they must outlive the gap.
+	cfg.AccessTokenTTL = time.Since(testNow) + time.Hour
+	cfg.PasswordResetTTL = cfg.AccessTokenTTL
+
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	t.Cleanup(func() { config.CloseDB(db) })
+	if err := seed.Run(db, seed.SetEmpty, cfg.UploadRoot); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := os.MkdirAll(cfg.UploadRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hashed, err := auth.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []models.User{
+		{UserName: adminUser, NickName: "管理员", Phone: "13800000001", Role: models.RoleAdmin},
+		{UserName: editorUser, NickName: "编辑", Phone: "13800000002", Role: models.RoleEditor},
+		{UserName: residentUser, NickName: "居民甲", Phone: residentPhone, Role: models.RoleResident},
+		{UserName: neighborUser, NickName: "居民乙", Phone: "13800000004", Role: models.RoleResident},
+	}
+	for _, u := range users {
+		u.PassWord = hashed
+		u.Sex = "0"
+		u.Status = models.UserStatusNormal
+		u.DelFlag = models.DelFlagExists
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatalf("create %s: %v", u.UserName, err)
+		}
+	}
+
+	h, err := handlers.New(handlers.Deps{DB: db, Config: cfg, Now: func() time.Time { return testNow }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return &testServer{t: t, engine: router.Setup(cfg, h), db: db, cfg: cfg, h: h}
+}
+
+type apiResponse struct {
+	status int
+	body   map[string]any
+}
+
+func (r apiResponse) code() int {
+	n, _ := r.body["code"].(float64)
+	return int(n)
+}
+
+func (r apiResponse) msg() string {
+	s, _ := r.body["msg"].(string)
+	return s
+}
+
+func (r apiResponse) data() map[string]any {
+	m, _ := r.body["data"].(map[string]any)
+	return m
+}
+
+func (r apiResponse) list() []any {
+	l, _ := r.body["data"].([]any)
+	return l
+}
+
+func (r apiResponse) total() int {
+	n, _ := r.body["total"].(float64)
+	return int(n)
+}
+
+// id returns the numeric data of a create response.
+func (r apiResponse) id() int {
+	n, _ := r.body["data"].(float64)
+	return int(n)
+}
+
+// call sends a request to path under the API prefix. body is JSON-encoded
+// unless it is already an io.Reader.
+func (s *testServer) call(method, path, token string, body any) apiResponse {
+	s.t.Helper()
+	return s.send(method, s.cfg.APIPrefix+path, token, body, "application/json")
+}
+
+func (s *testServer) send(method, url, token string, body any, contentType string) apiResponse {
+	s.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, url, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if s.strict {
+		req.Header.Set(middleware.ResponseModeHeader, "strict")
+	}
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+
+	resp := apiResponse{status: rec.Code}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp.body); err != nil {
+		s.t.Fatalf("%s %s: response is not JSON (%d): %s", method, url, rec.Code, rec.Body.String())
+	}
+	return resp
+}
+
+// ok fails the test unless the response carries code 200.
+func (s *testServer) ok(resp apiResponse) apiResponse {
+	s.t.Helper()
+	if resp.code() != 200 {
+		s.t.Fatalf("want code 200, got %d: %v", resp.code(), resp.body)
+	}
+	return resp
+}
+
+func (s *testServer) expect(resp apiResponse, code int, msg string) {
+	s.t.Helper()
+	if resp.code() != code || (msg != "" && resp.msg() != msg) {
+		s.t.Fatalf("want code %d %q, got %d %q", code, msg, resp.code(), resp.msg())
+	}
+}
+
+func (s *testServer) login(userName string) string {
+	s.t.Helper()
+	resp := s.ok(s.call("POST", "/login", "", map[string]string{"userName": userName, "password": testPassword}))
+	token, _ := resp.body["token"].(string)
+	if token == "" {
+		s.t.Fatalf("login %s: no token in %v", userName, resp.body)
+	}
+	return token
+}
+
+// assertGolden compares v, encoded as indented JSON, with
+// testdata/golden/<name>.json. Run "go test ./internal/router -update" after
+// an intended response change.
+func assertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed:\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+// shapeOf replaces every value with its JSON type and keeps only the first
+// element of arrays, for responses whose values vary between runs.
+func shapeOf(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = shapeOf(item)
+		}
+		return out
+	case []any:
+		if len(v) == 0 {
+			return []any{}
+		}
+		return []any{shapeOf(v[0])}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func count[T any](t *testing.T, db *gorm.DB, query string, args ...any) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(new(T)).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
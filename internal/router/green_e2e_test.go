@@ -0,0 +1,124 @@
+package router_test
+
+import (
+	"digital-community/internal/models"
+	"fmt"
+	"testing"
+)
+
+func TestQuizQuestionsAndPaper(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	for i := 1; i <= 7; i++ {
+		s.ok(s.call("POST", "/question", editor, map[string]any{
+			"questionType": "1",
+			"level":        "1",
+			"question":     fmt.Sprintf("第%d题：废电池属于哪类垃圾？", i),
+			"optionA":      "可回收物",
+			"optionB":      "有害垃圾",
+			"answer":       "B",
+			"score":        20,
+			"status":       "0",
+		}))
+	}
+	// Disabled questions are never drawn.
+	s.ok(s.call("POST", "/question", editor, map[string]any{"questionType": "1", "level": "1", "question": "停用", "answer": "A", "status": "1"}))
+
+	s.expect(s.call("GET", "/question/questionList/2/1", resident, nil), 500, "题型参数错误")
+	s.expect(s.call("GET", "/question/questionList/1/9", resident, nil), 500, "难度参数错误")
+	if empty := s.ok(s.call("GET", "/question/questionList/4/3", resident, nil)); empty.total() != 0 || len(empty.list()) != 0 {
+		t.Fatalf("no questions of type 4: %v", empty.body)
+	}
+
+	drawn := s.ok(s.call("GET", "/question/questionList/1/1", resident, nil))
+	if drawn.total() != 7 || len(drawn.list()) != 5 {
+		t.Fatalf("want 5 of 7 questions, got %d of %d", len(drawn.list()), drawn.total())
+	}
+	assertGolden(t, "question_list", shapeOf(drawn.body))
+
+	var answers []map[string]any
+	for i, q := range drawn.list() {
+		answer := "B"
+		if i == 0 {
+			answer = "A"
+		}
+		answers = append(answers, map[string]any{"qid": q.(map[string]any)["id"], "answer": answer})
+	}
+	s.expect(s.call("POST", "/question/savePaper", resident, map[string]any{"score": 80}), 500, "答案不能为空")
+	s.expect(s.call("POST", "/question/savePaper", resident, map[string]any{"answer": answers}), 500, "分数不能为空")
+	s.expect(s.call("POST", "/question/savePaper", resident, map[string]any{"score": 80, "answer": []map[string]any{{"qid": 9999, "answer": "A"}}}), 500, "题目不存在")
+	s.ok(s.call("POST", "/question/savePaper", resident, map[string]any{"score": 80, "answer": answers}))
+
+	if n := count[models.GreenPaper](t, s.db, "score = ?", "80"); n != 1 {
+		t.Fatalf("want one saved paper, got %d", n)
+	}
+	if n := count[models.GreenPaperAnswer](t, s.db, "is_correct = ?", "1"); n != 4 {
+		t.Fatalf("want 4 correct answers, got %d", n)
+	}
+	if n := count[models.GreenPaperAnswer](t, s.db, "is_correct = ?", "0"); n != 1 {
+		t.Fatalf("want 1 wrong answer, got %d", n)
+	}
+}
+
+func TestGreenQuestionAdmin(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+
+	s.ok(s.call("POST", "/question", editor, map[string]any{"questionType": "4", "level": "2", "question": "判断题", "answer": "A", "status": "0"}))
+	list := s.ok(s.call("GET", "/question/list", editor, nil))
+	if list.total() != 1 {
+		t.Fatalf("question list: %v", list.body)
+	}
+	if resident := s.ok(s.call("GET", "/question/list", s.login(residentUser), nil)); resident.total() != 1 {
+		t.Fatalf("residents read the question list: %v", resident.body)
+	}
+	id := int(list.list()[0].(map[string]any)["id"].(float64))
+	s.ok(s.call("PUT", fmt.Sprintf("/question/%d", id), editor, map[string]any{"questionType": "4", "level": "3", "question": "判断题（改）", "answer": "B", "status": "0"}))
+	if n := count[models.GreenQuestion](t, s.db, "level = ? AND answer = ?", "3", "B"); n != 1 {
+		t.Fatalf("question not updated")
+	}
+	s.ok(s.call("DELETE", fmt.Sprintf("/question/%d", id), editor, nil))
+	if list := s.ok(s.call("GET", "/question/list", editor, nil)); list.total() != 0 {
+		t.Fatalf("question not deleted")
+	}
+}
+
+func TestGreenDataCardsAndSeries(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+
+	s.ok(s.call("POST", "/common/datacard", editor, map[string]any{"icon": "leaf", "title": "减碳量", "num": "12.5", "unit": "吨", "trend": "up", "sort": 2}))
+	s.ok(s.call("POST", "/common/datacard", editor, map[string]any{"icon": "drop", "title": "节水量", "num": "300", "unit": "吨", "trend": "down", "sort": 1}))
+	cards := s.ok(s.call("GET", "/common/datacard", "", nil))
+	assertGolden(t, "datacard_list", cards.body)
+
+	first := int(cards.list()[0].(map[string]any)["id"].(float64))
+	s.ok(s.call("PUT", fmt.Sprintf("/common/datacard/%d", first), editor, map[string]any{"icon": "drop", "title": "节水量", "num": "310", "unit": "吨", "trend": "up", "sort": 1}))
+	s.ok(s.call("DELETE", fmt.Sprintf("/common/datacard/%d", first), editor, nil))
+	if cards := s.ok(s.call("GET", "/common/datacard", "", nil)); cards.total() != 1 {
+		t.Fatalf("datacard not deleted: %v", cards.body)
+	}
+
+	s.expect(s.call("POST", "/data/list", editor, map[string]string{}), 500, "数据不能为空")
+	created := s.ok(s.call("POST", "/data/list", editor, map[string]string{"data": `[{"name":"一月","data":[1,2,3]}]`})).data()
+	key := created["listKey"].(string)
+	series := s.ok(s.call("GET", "/data/"+key, "", nil))
+	assertGolden(t, "data_series", series.body)
+	s.expect(s.call("GET", "/data/list_404", "", nil), 404, "数据不存在")
+
+	list := s.ok(s.call("GET", "/data/list", editor, nil))
+	var id int
+	for _, item := range list.list() {
+		if row := item.(map[string]any); row["listKey"] == key {
+			id = int(row["id"].(float64))
+		}
+	}
+	s.ok(s.call("PUT", fmt.Sprintf("/data/list/%d", id), editor, map[string]string{"data": `[{"name":"二月","data":[4]}]`}))
+	if data := s.ok(s.call("GET", "/data/"+key, "", nil)).body["data"].([]any); data[0].(map[string]any)["name"] != "二月" {
+		t.Fatalf("series not updated: %v", data)
+	}
+	s.ok(s.call("DELETE", fmt.Sprintf("/data/list/%d", id), editor, nil))
+	s.expect(s.call("GET", "/data/"+key, "", nil), 404, "数据不存在")
+}
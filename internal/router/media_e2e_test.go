@@ -0,0 +1,165 @@
+package router_test
+
+import (
+	"bytes"
+	"digital-community/internal/router"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func (s *testServer) upload(token, name string, content []byte) apiResponse {
+	s.t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+	return s.send("POST", s.cfg.APIPrefix+"/common/upload", token, &buf, w.FormDataContentType())
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadListAndDeleteImages(t *testing.T) {
+	s := newTestServer(t)
+	resident := s.login(residentUser)
+	editor := s.login(editorUser)
+
+	resp := s.ok(s.upload(resident, "my photo.png", testPNG(t, 400, 200)))
+	imageURL := resp.body["url"].(string)
+	if imageURL != "/profile/upload/image/20240501093000_my_photo.png" {
+		t.Fatalf("upload url %q", imageURL)
+	}
+	thumb := filepath.Join(s.cfg.UploadRoot, "thumb", "image", "20240501093000_my_photo.jpg")
+	if _, err := os.Stat(thumb); err != nil {
+		t.Fatalf("thumbnail not generated: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, httptest.NewRequest("GET", imageURL, nil))
+	if rec.Code != 200 || rec.Body.Len() == 0 {
+		t.Fatalf("uploaded image not served: %d", rec.Code)
+	}
+
+	s.ok(s.upload(resident, "notes.txt", []byte("hello")))
+	files := s.ok(s.call("GET", "/common/files", "", nil))
+	if files.total() != 1 {
+		t.Fatalf("file list: %v", files.body)
+	}
+	images := s.ok(s.call("GET", "/common/images", "", nil))
+	if images.total() != 1 {
+		t.Fatalf("image list: %v", images.body)
+	}
+	item := images.list()[0].(map[string]any)
+	if item["thumbUrl"] != "/profile/upload/thumb/image/20240501093000_my_photo.jpg" {
+		t.Fatalf("thumbUrl %v", item["thumbUrl"])
+	}
+	assertGolden(t, "image_list", shapeOf(images.body))
+
+	s.expect(s.call("DELETE", "/common/images?url="+url.QueryEscape(imageURL), resident, nil), 403, "无权限")
+	s.expect(s.call("DELETE", "/common/images?url="+url.QueryEscape("/profile/upload/../../etc/passwd"), editor, nil), 500, "参数错误")
+	s.ok(s.call("DELETE", "/common/images?url="+url.QueryEscape(imageURL), editor, nil))
+	if _, err := os.Stat(thumb); !os.IsNotExist(err) {
+		t.Fatalf("thumbnail should be removed with the image")
+	}
+	s.expect(s.call("DELETE", "/common/images?url="+url.QueryEscape(imageURL), editor, nil), 404, "文件不存在")
+}
+
+func TestHealthVersionAndBackup(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(adminUser)
+
+	s.ok(s.send("GET", "/health", "", nil, ""))
+	ready := s.ok(s.send("GET", "/ready", "", nil, ""))
+	if ready.data()["database"] != "ok" || ready.data()["uploadDir"] != "ok" {
+		t.Fatalf("ready: %v", ready.body)
+	}
+	// A missing upload directory (e.g. an unmounted volume) fails the probe
+	// without being recreated, and the error detail stays out of the body.
+	moved := s.cfg.UploadRoot + ".unmounted"
+	if err := os.Rename(s.cfg.UploadRoot, moved); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.send("GET", "/ready", "", nil, ""), 503, "not ready")
+	if notReady := s.send("GET", "/ready", "", nil, ""); notReady.data()["uploadDir"] != "error" {
+		t.Fatalf("ready without upload dir: %v", notReady.body)
+	}
+	if _, err := os.Stat(s.cfg.UploadRoot); !os.IsNotExist(err) {
+		t.Fatal("readiness probe must not create the upload directory")
+	}
+	if err := os.Rename(moved, s.cfg.UploadRoot); err != nil {
+		t.Fatal(err)
+	}
+	version := s.ok(s.send("GET", "/version", "", nil, ""))
+	if version.data()["schemaVersion"] != version.data()["latestSchema"] {
+		t.Fatalf("schema not current: %v", version.data())
+	}
+
+	created := s.ok(s.call("POST", "/backup", admin, nil)).data()
+	name := created["name"].(string)
+	if list := s.ok(s.call("GET", "/backup/list", admin, nil)); list.total() != 1 {
+		t.Fatalf("backup list: %v", list.body)
+	}
+
+	req := httptest.NewRequest("GET", s.cfg.APIPrefix+"/backup/"+name, nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	if rec.Code != 200 || !strings.Contains(rec.Header().Get("Content-Disposition"), name) {
+		t.Fatalf("backup download: %d %v", rec.Code, rec.Header())
+	}
+	s.expect(s.call("GET", "/backup/backup-19990101-000000.tar.gz", admin, nil), 404, "备份不存在")
+}
+
+func TestMetricsNeedToken(t *testing.T) {
+	s := newTestServer(t)
+	scrape := func(engine http.Handler, token string) int {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := scrape(s.engine, ""); code != http.StatusNotFound {
+		t.Fatalf("metrics without METRICS_TOKEN: %d, want 404", code)
+	}
+
+	cfg := *s.cfg
+	cfg.MetricsToken = "scrape-secret"
+	engine := router.Setup(&cfg, s.h)
+	if code := scrape(engine, ""); code != http.StatusUnauthorized {
+		t.Fatalf("metrics without token: %d, want 401", code)
+	}
+	if code := scrape(engine, "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("metrics with wrong token: %d, want 401", code)
+	}
+	if code := scrape(engine, "scrape-secret"); code != http.StatusOK {
+		t.Fatalf("metrics with token: %d, want 200", code)
+	}
+}
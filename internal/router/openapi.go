@@ -0,0 +1,338 @@
+package router
+
+import (
+	"digital-community/internal/buildinfo"
+	"digital-community/internal/config"
+	"digital-community/internal/handlers"
+	"digital-community/internal/middleware"
+	"digital-community/internal/models"
+	"digital-community/internal/openapi"
+	"log/slog"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authLogin marks routes open to any logged-in user; other non-empty auth
+// values name the permission the route requires.
+const authLogin = "login"
+
+type routeDoc struct {
+	tag     string
+	summary string
+	auth    string
+	query   []openapi.Parameter
+	body    any
+	upload  bool // multipart form with a "file" field
+	list    bool // list envelope with total
+	file    bool // binary download instead of JSON
+}
+
+func query(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func requiredQuery(name, description string) openapi.Parameter {
+	p := query(name, description)
+	p.Required = true
+	return p
+}
+
+var paging = []openapi.Parameter{
+	{Name: "pageNum", In: "query", Description: "页码，从 1 开始", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "pageSize", In: "query", Description: "每页条数", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+func withPaging(params ...openapi.Parameter) []openapi.Parameter {
+	return append(params, paging...)
+}
+
+// newsFilters are the query parameters read by Press.filterNews.
+var newsFilters = []openapi.Parameter{
+	query("status", "状态"),
+	query("top", "是否置顶：Y 或 N"),
+	query("hot", "是否热门：Y 或 N"),
+	query("tag", "标签，匹配逗号分隔的 tags 中的一项"),
+	query("keyword", "标题关键词"),
+	query("startDate", "发布日期起，格式 2006-01-02，含当天"),
+	query("endDate", "发布日期止，格式 2006-01-02，含当天"),
+	query("sort", "排序字段：publishDate（默认）、readNum、likeNum；置顶文章始终在前"),
+	query("order", "排序方向：desc（默认）或 asc"),
+}
+
+// apiDocs describes every route under the API prefix, keyed by method and
+// path relative to the prefix. TestOpenAPICoversAllRoutes fails when a route
+// registered in Setup is missing here.
+var apiDocs = map[string]routeDoc{
+	"POST /phone/login":     {tag: "认证", summary: "手机验证码登录", body: handlers.PhoneLoginRequest{}},
+	"POST /login":           {tag: "认证", summary: "用户名密码登录", body: handlers.LoginRequest{}},
+	"POST /token/refresh":   {tag: "认证", summary: "刷新令牌", body: handlers.RefreshTokenRequest{}},
+	"GET /smsCode":          {tag: "认证", summary: "获取登录短信验证码", query: []openapi.Parameter{requiredQuery("phone", "手机号")}},
+	"GET /password/smsCode": {tag: "认证", summary: "获取找回密码短信验证码", query: []openapi.Parameter{requiredQuery("phone", "手机号")}},
+	"POST /password/verify": {tag: "认证", summary: "校验找回密码验证码，返回重置令牌", body: handlers.PasswordVerifyRequest{}},
+	"POST /password/reset":  {tag: "认证", summary: "使用重置令牌设置新密码", body: handlers.PasswordResetRequest{}},
+	"POST /register":        {tag: "认证", summary: "注册", body: handlers.RegisterRequest{}},
+
+	"GET /user/getUserInfo":     {tag: "用户", summary: "获取当前用户信息", auth: authLogin},
+	"PUT /user/updateUserInfo":  {tag: "用户", summary: "更新当前用户信息", auth: authLogin, body: handlers.UpdateUserInfoRequest{}},
+	"PUT /user/resetPwd":        {tag: "用户", summary: "修改密码", auth: authLogin, body: handlers.ResetPwdRequest{}},
+	"POST /user/logoutAll":      {tag: "用户", summary: "退出全部会话", auth: authLogin},
+	"GET /user/list":            {tag: "用户", summary: "用户列表", auth: middleware.PermUserManage, list: true, query: withPaging(query("role", "角色"), query("status", "状态"))},
+	"POST /user":                {tag: "用户", summary: "创建用户", auth: middleware.PermUserManage, body: handlers.UserCreateRequest{}},
+	"PUT /user/:id":             {tag: "用户", summary: "修改用户", auth: middleware.PermUserManage, body: handlers.UserUpdateRequest{}},
+	"DELETE /user/:id":          {tag: "用户", summary: "删除用户", auth: middleware.PermUserManage},
+	"PUT /user/:id/forceLogout": {tag: "用户", summary: "强制用户下线", auth: middleware.PermUserManage},
+	"PUT /user/:id/unlock":      {tag: "用户", summary: "解除登录锁定", auth: middleware.PermUserManage},
+
+	"GET /rotation/list":   {tag: "轮播图", summary: "轮播图列表", list: true, query: withPaging(requiredQuery("type", "轮播图类型"))},
+	"POST /rotation":       {tag: "轮播图", summary: "创建轮播图", auth: middleware.PermContentManage, body: handlers.RotationCreateRequest{}},
+	"PUT /rotation/:id":    {tag: "轮播图", summary: "修改轮播图", auth: middleware.PermContentManage, body: handlers.RotationUpdateRequest{}},
+	"DELETE /rotation/:id": {tag: "轮播图", summary: "删除轮播图", auth: middleware.PermContentManage},
+
+	"GET /press/category/list":     {tag: "新闻", summary: "新闻分类列表", auth: authLogin, list: true},
+	"POST /press/category":         {tag: "新闻", summary: "创建新闻分类", auth: middleware.PermContentManage, body: handlers.PressCategoryCreateRequest{}},
+	"PUT /press/category/:id":      {tag: "新闻", summary: "修改新闻分类", auth: middleware.PermContentManage, body: handlers.PressCategoryUpdateRequest{}},
+	"DELETE /press/category/:id":   {tag: "新闻", summary: "删除新闻分类", auth: middleware.PermContentManage},
+	"GET /press/newsList":          {tag: "新闻", summary: "新闻列表，支持筛选和排序", auth: authLogin, list: true, query: withPaging(append([]openapi.Parameter{query("categoryId", "分类 ID")}, newsFilters...)...)},
+	"GET /press/category/newsList": {tag: "新闻", summary: "分类新闻列表，支持与新闻列表相同的筛选和排序", auth: authLogin, list: true, query: withPaging(append([]openapi.Parameter{requiredQuery("id", "分类 ID")}, newsFilters...)...)},
+	"GET /press/news/:id":          {tag: "新闻", summary: "新闻详情，同时累计阅读数", auth: authLogin},
+	"POST /press/news":             {tag: "新闻", summary: "发布新闻", auth: middleware.PermContentManage, body: handlers.PressNewsCreateRequest{}},
+	"PUT /press/news/:id":          {tag: "新闻", summary: "修改新闻，只更新传入的字段", auth: middleware.PermContentManage, body: handlers.PressNewsUpdateRequest{}},
+	"DELETE /press/news/:id":       {tag: "新闻", summary: "删除新闻", auth: middleware.PermContentManage},
+	"PUT /press/like/:id":          {tag: "新闻", summary: "点赞新闻", auth: authLogin},
+
+	"POST /comment/pressComment": {tag: "评论", summary: "评论新闻", auth: authLogin, body: handlers.PressCommentRequest{}},
+	"GET /comment/comment/:id":   {tag: "评论", summary: "新闻评论列表", auth: authLogin, list: true, query: paging},
+	"PUT /comment/like/:id":      {tag: "评论", summary: "点赞评论", auth: authLogin},
+
+	"GET /notice/list":    {tag: "公告", summary: "公告列表", list: true, query: withPaging(query("noticeStatus", "0 未读，1 已读"))},
+	"GET /notice/:id":     {tag: "公告", summary: "公告详情", auth: authLogin},
+	"PUT /readNotice/:id": {tag: "公告", summary: "标记公告已读", auth: authLogin},
+	"POST /notice":        {tag: "公告", summary: "发布公告", auth: middleware.PermContentManage, body: handlers.NoticeCreateRequest{}},
+	"PUT /notice/:id":     {tag: "公告", summary: "修改公告", auth: middleware.PermContentManage, body: handlers.NoticeUpdateRequest{}},
+	"DELETE /notice/:id":  {tag: "公告", summary: "删除公告", auth: middleware.PermContentManage},
+
+	"GET /friendly_neighborhood/list":         {tag: "友邻圈", summary: "友邻圈列表", list: true, query: paging},
+	"POST /friendly_neighborhood/add/comment": {tag: "友邻圈", summary: "评论友邻圈动态", body: handlers.FriendlyNeighborAddCommentRequest{}},
+	"GET /friendly_neighborhood/:id":          {tag: "友邻圈", summary: "动态详情及评论"},
+	"POST /friendly_neighborhood":             {tag: "友邻圈", summary: "发布动态", auth: authLogin, body: handlers.FriendlyNeighborCreateRequest{}},
+	"PUT /friendly_neighborhood/:id":          {tag: "友邻圈", summary: "修改动态，限发布者或内容管理员", auth: authLogin, body: handlers.FriendlyNeighborUpdateRequest{}},
+	"DELETE /friendly_neighborhood/:id":       {tag: "友邻圈", summary: "删除动态，限发布者或内容管理员", auth: authLogin},
+
+	"GET /activity/topList":           {tag: "活动", summary: "置顶活动，没有置顶时返回全部活动", list: true, query: paging},
+	"GET /activity/list":              {tag: "活动", summary: "活动列表", list: true, query: paging},
+	"POST /activity/search":           {tag: "活动", summary: "按标题搜索活动", list: true, query: paging, body: handlers.ActivitySearchRequest{}},
+	"GET /activity/category/list/:id": {tag: "活动", summary: "分类活动列表", list: true, query: paging},
+	"GET /activity/:id":               {tag: "活动", summary: "活动详情"},
+	"POST /activity":                  {tag: "活动", summary: "创建活动", auth: middleware.PermContentManage, body: handlers.ActivityCreateRequest{}},
+	"PUT /activity/:id":               {tag: "活动", summary: "修改活动，只更新传入的字段", auth: middleware.PermContentManage, body: handlers.ActivityUpdateRequest{}},
+	"DELETE /activity/:id":            {tag: "活动", summary: "删除活动", auth: middleware.PermContentManage},
+
+	"POST /registration":            {tag: "报名", summary: "报名活动", auth: authLogin, body: handlers.RegistrationRequest{}},
+	"PUT /checkin/:id":              {tag: "报名", summary: "活动签到", auth: authLogin},
+	"PUT /registration/comment/:id": {tag: "报名", summary: "评价已报名的活动", auth: authLogin, body: handlers.RegistrationCommentRequest{}},
+	"GET /registration/list":        {tag: "报名", summary: "报名列表，居民只能看到自己的报名", auth: authLogin, list: true, query: withPaging(query("activityId", "活动 ID"), query("userId", "用户 ID"))},
+
+	"GET /search": {tag: "搜索", summary: "统一搜索新闻、公告、活动和友邻圈，按相关度排序并返回高亮摘要", auth: authLogin, list: true,
+		query: withPaging(requiredQuery("keyword", "关键词，空格分隔多个词时须全部命中"), query("type", "逗号分隔的类型过滤：news、notice、activity、neighbor，默认全部"))},
+
+	"POST /common/upload":   {tag: "上传", summary: "上传文件，图片会同时生成缩略图", auth: authLogin, upload: true},
+	"GET /common/images":    {tag: "上传", summary: "已上传图片列表", list: true, query: paging},
+	"GET /common/files":     {tag: "上传", summary: "已上传文件列表", list: true, query: paging},
+	"DELETE /common/images": {tag: "上传", summary: "删除图片及其缩略图", auth: middleware.PermMediaManage, query: []openapi.Parameter{requiredQuery("url", "上传接口返回的 url")}},
+	"DELETE /common/files":  {tag: "上传", summary: "删除文件", auth: middleware.PermMediaManage, query: []openapi.Parameter{requiredQuery("url", "上传接口返回的 url")}},
+
+	"GET /common/datacard":                  {tag: "绿色生活", summary: "数据卡片列表", list: true},
+	"POST /common/datacard":                 {tag: "绿色生活", summary: "创建数据卡片", auth: middleware.PermContentManage, body: handlers.GreenDataCardCreateRequest{}},
+	"PUT /common/datacard/:id":              {tag: "绿色生活", summary: "修改数据卡片", auth: middleware.PermContentManage, body: handlers.GreenDataCardUpdateRequest{}},
+	"DELETE /common/datacard/:id":           {tag: "绿色生活", summary: "删除数据卡片", auth: middleware.PermContentManage},
+	"GET /question/questionList/:id/:level": {tag: "绿色生活", summary: "按题型和难度随机抽题", auth: authLogin, list: true},
+	"POST /question/savePaper":              {tag: "绿色生活", summary: "提交答卷", auth: authLogin, body: handlers.QuestionSavePaperRequest{}},
+	"GET /question/list":                    {tag: "绿色生活", summary: "题库列表", auth: authLogin, list: true, query: paging},
+	"POST /question":                        {tag: "绿色生活", summary: "创建题目", auth: middleware.PermContentManage, body: models.GreenQuestion{}},
+	"PUT /question/:id":                     {tag: "绿色生活", summary: "修改题目", auth: middleware.PermContentManage, body: handlers.GreenQuestionUpdateRequest{}},
+	"DELETE /question/:id":                  {tag: "绿色生活", summary: "删除题目", auth: middleware.PermContentManage},
+	"GET /data/:listKey":                    {tag: "绿色生活", summary: "按 listKey 获取图表数据"},
+	"GET /data/list":                        {tag: "绿色生活", summary: "图表数据列表", auth: authLogin, list: true},
+	"POST /data/list":                       {tag: "绿色生活", summary: "创建图表数据", auth: middleware.PermContentManage, body: handlers.GreenDataSeriesCreateRequest{}},
+	"PUT /data/list/:id":                    {tag: "绿色生活", summary: "修改图表数据", auth: middleware.PermContentManage, body: handlers.GreenDataSeriesUpdateRequest{}},
+	"DELETE /data/list/:id":                 {tag: "绿色生活", summary: "删除图表数据", auth: middleware.PermContentManage},
+
+	"POST /backup":      {tag: "备份", summary: "立即创建备份", auth: middleware.PermSystemManage},
+	"GET /backup/list":  {tag: "备份", summary: "备份列表，按时间倒序", auth: middleware.PermSystemManage, list: true},
+	"GET /backup/:name": {tag: "备份", summary: "下载备份文件", auth: middleware.PermSystemManage, file: true},
+}
+
+// rootDocs describes the routes registered outside the API prefix.
+var rootDocs = map[string]routeDoc{
+	"GET /health":  {tag: "运维", summary: "存活检查，进程正常即返回 200"},
+	"GET /ready":   {tag: "运维", summary: "就绪检查：数据库可连接且上传目录可写时返回 200，否则返回 503"},
+	"GET /version": {tag: "运维", summary: "版本号、构建提交、构建时间、启动时间与数据库结构版本"},
+	"GET /metrics": {tag: "运维", summary: "Prometheus 指标，仅在配置 METRICS_TOKEN 时提供，需携带 Authorization: Bearer <METRICS_TOKEN>", file: true},
+	"POST /logout": {tag: "认证", summary: "退出当前会话，可同时吊销刷新令牌", auth: authLogin, body: handlers.LogoutRequest{}},
+}
+
+// routeDocFor looks up the documentation of a registered route.
+func routeDocFor(cfg *config.Config, method, fullPath string) (routeDoc, bool) {
+	if rel, ok := strings.CutPrefix(fullPath, cfg.APIPrefix); ok && strings.HasPrefix(rel, "/") {
+		doc, ok := apiDocs[method+" "+rel]
+		return doc, ok
+	}
+	doc, ok := rootDocs[method+" "+fullPath]
+	return doc, ok
+}
+
+// undocumented reports whether a route is deliberately left out of the
+// document: static files and the documentation routes themselves.
+func undocumented(cfg *config.Config, route gin.RouteInfo) bool {
+	return strings.HasPrefix(route.Path, "/profile/upload/") || strings.HasPrefix(route.Path, docsBase(cfg)+"/swagger/") ||
+		route.Path == docsBase(cfg)+"/openapi.json"
+}
+
+// docsBase is the parent of the API prefix, e.g. /prod-api for /prod-api/api.
+func docsBase(cfg *config.Config) string {
+	return strings.TrimSuffix(path.Dir(cfg.APIPrefix), "/")
+}
+
+func buildOpenAPI(cfg *config.Config, routes gin.RoutesInfo) *openapi.Document {
+	reg := openapi.NewRegistry()
+	envelope := reg.SchemaOf(handlers.Response{})
+	listEnvelope := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"code":  {Type: "integer"},
+			"msg":   {Type: "string"},
+			"data":  {Type: "array", Items: &openapi.Schema{}},
+			"total": {Type: "integer", Format: "int64"},
+		},
+	}
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "数字社区 API",
+			Description: "除 /health、/ready、/version、/metrics 外，接口的业务结果都在响应体的 code 字段中，HTTP 状态码固定为 200；请求头 X-Response-Mode: strict 时改为返回对应的 HTTP 状态码。",
+			Version:     buildinfo.Version,
+		},
+		Paths: map[string]*openapi.PathItem{},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	seen := map[string]bool{}
+	documented := map[string]bool{}
+	for _, route := range routes {
+		if undocumented(cfg, route) {
+			continue
+		}
+		rd, ok := routeDocFor(cfg, route.Method, route.Path)
+		if !ok {
+			slog.Warn("route missing from openapi document", "method", route.Method, "path", route.Path)
+			continue
+		}
+		documented[route.Method+" "+route.Path] = true
+
+		op := &openapi.Operation{
+			Tags:        []string{rd.tag},
+			Summary:     rd.summary,
+			OperationID: handlerName(route.Handler),
+			Parameters:  append(pathParams(route.Path), rd.query...),
+			Responses:   map[string]*openapi.Response{},
+		}
+		switch rd.auth {
+		case "":
+		case authLogin:
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+		default:
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			op.Description = "需要权限：" + rd.auth
+		}
+		if rd.body != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSONContent(reg.SchemaOf(rd.body))}
+		}
+		if rd.upload {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+				"multipart/form-data": {Schema: &openapi.Schema{
+					Type:       "object",
+					Properties: map[string]*openapi.Schema{"file": {Type: "string", Format: "binary"}},
+					Required:   []string{"file"},
+				}},
+			}}
+		}
+		switch {
+		case rd.file:
+			op.Responses["200"] = &openapi.Response{Description: "文件内容"}
+		case rd.list:
+			op.Responses["200"] = &openapi.Response{Description: "列表", Content: openapi.JSONContent(listEnvelope)}
+		default:
+			op.Responses["200"] = &openapi.Response{Description: "业务结果见 code 字段", Content: openapi.JSONContent(envelope)}
+		}
+
+		p := openAPIPath(route.Path)
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = &openapi.PathItem{}
+		}
+		(*doc.Paths[p])[strings.ToLower(route.Method)] = op
+		if !seen[rd.tag] {
+			seen[rd.tag] = true
+			doc.Tags = append(doc.Tags, openapi.Tag{Name: rd.tag})
+		}
+	}
+	for key := range apiDocs {
+		method, rel, _ := strings.Cut(key, " ")
+		if !documented[method+" "+cfg.APIPrefix+rel] {
+			slog.Warn("openapi entry has no matching route", "route", key)
+		}
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components.Schemas = reg.Schemas
+	return doc
+}
+
+// openAPIPath turns gin's /news/:id into /news/{id}.
+func openAPIPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func pathParams(p string) []openapi.Parameter {
+	var params []openapi.Parameter
+	for _, part := range strings.Split(p, "/") {
+		name, ok := strings.CutPrefix(part, ":")
+		if !ok {
+			continue
+		}
+		schema := &openapi.Schema{Type: "string"}
+		if name == "id" {
+			schema.Type = "integer"
+		}
+		params = append(params, openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+// handlerName turns "digital-community/internal/handlers.(*Press).PressNewsList-fm"
+// into "PressNewsList".
+func handlerName(full string) string {
+	name := full[strings.LastIndex(full, ".")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func serveOpenAPI(r *gin.Engine, cfg *config.Config) {
+	doc := buildOpenAPI(cfg, r.Routes())
+	r.GET(docsBase(cfg)+"/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+	mountSwaggerUI(r, docsBase(cfg)+"/swagger")
+}
@@ -0,0 +1,89 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]any `json:"properties"`
+			Required   []string       `json:"required"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func (s *testServer) openAPI() openAPIDoc {
+	s.t.Helper()
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, httptest.NewRequest("GET", "/prod-api/openapi.json", nil))
+	if rec.Code != 200 {
+		s.t.Fatalf("openapi.json: %d", rec.Code)
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		s.t.Fatal(err)
+	}
+	return doc
+}
+
+// TestOpenAPICoversAllRoutes fails when a route is registered without an
+// entry in apiDocs or rootDocs.
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	s := newTestServer(t)
+	doc := s.openAPI()
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("openapi version %q", doc.OpenAPI)
+	}
+
+	for _, route := range s.engine.Routes() {
+		if strings.HasPrefix(route.Path, "/profile/upload/") || strings.HasPrefix(route.Path, "/prod-api/swagger") || route.Path == "/prod-api/openapi.json" {
+			continue
+		}
+		parts := strings.Split(route.Path, "/")
+		for i, part := range parts {
+			if strings.HasPrefix(part, ":") {
+				parts[i] = "{" + part[1:] + "}"
+			}
+		}
+		p := strings.Join(parts, "/")
+		if _, ok := doc.Paths[p][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s has no OpenAPI entry", route.Method, route.Path)
+		}
+	}
+}
+
+func TestOpenAPIRequestSchemas(t *testing.T) {
+	s := newTestServer(t)
+	doc := s.openAPI()
+
+	register, ok := doc.Components.Schemas["RegisterRequest"]
+	if !ok {
+		t.Fatalf("RegisterRequest schema missing")
+	}
+	if _, ok := register.Properties["phoneNumber"]; !ok || strings.Join(register.Required, ",") != "userName,sex" {
+		t.Fatalf("RegisterRequest: %+v", register)
+	}
+	if _, ok := doc.Paths["/prod-api/api/activity/{id}"]["put"]; !ok {
+		t.Fatalf("path parameters should use OpenAPI syntax")
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	s := newTestServer(t)
+	for path, want := range map[string]string{
+		"/prod-api/swagger/":                     "../openapi.json",
+		"/prod-api/swagger/swagger-ui-bundle.js": "SwaggerUIBundle",
+	} {
+		rec := httptest.NewRecorder()
+		s.engine.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: %d, body lacks %q", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package router_test
+
+import (
+	"digital-community/internal/models"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPressCategoryCRUD(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	before := s.ok(s.call("GET", "/press/category/list", resident, nil)).total()
+	id := s.ok(s.call("POST", "/press/category", editor, map[string]any{"name": "测试分类", "sort": 9})).id()
+
+	list := s.ok(s.call("GET", "/press/category/list", resident, nil))
+	if list.total() != before+1 {
+		t.Fatalf("want %d categories, got %d", before+1, list.total())
+	}
+	assertGolden(t, "press_category_list", shapeOf(list.body))
+
+	s.ok(s.call("PUT", fmt.Sprintf("/press/category/%d", id), editor, map[string]any{"name": "改名分类"}))
+	s.expect(s.call("PUT", "/press/category/9999", editor, map[string]any{"name": "x"}), 404, "分类不存在")
+	s.ok(s.call("DELETE", fmt.Sprintf("/press/category/%d", id), editor, nil))
+	s.expect(s.call("DELETE", fmt.Sprintf("/press/category/%d", id), editor, nil), 404, "分类不存在")
+}
+
+func TestPressNewsCRUDAndDetailShape(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	s.expect(s.call("POST", "/press/news", editor, map[string]any{"title": "缺正文", "categoryId": 1}), 500, "参数错误")
+	id := s.ok(s.call("POST", "/press/news", editor, map[string]any{
+		"title":      "社区垃圾分类新规",
+		"subTitle":   "五月起执行",
+		"content":    "<p>正文</p>",
+		"categoryId": 1,
+		"type":       "1",
+		"imageUrls":  "/profile/upload/image/cover.png",
+	})).id()
+
+	detail := s.ok(s.call("GET", fmt.Sprintf("/press/news/%d", id), resident, nil))
+	assertGolden(t, "press_news_detail", detail.body)
+	if detail.data()["readNum"].(float64) != 1 {
+		t.Fatalf("detail should count a read: %v", detail.data())
+	}
+
+	s.ok(s.call("PUT", fmt.Sprintf("/press/news/%d", id), editor, map[string]any{"title": "新标题", "status": "1"}))
+	data := s.ok(s.call("GET", fmt.Sprintf("/press/news/%d", id), resident, nil)).data()
+	if data["title"] != "新标题" || data["status"] != "1" || data["subTitle"] != "五月起执行" {
+		t.Fatalf("update should change only the given fields: %v", data)
+	}
+
+	s.ok(s.call("DELETE", fmt.Sprintf("/press/news/%d", id), editor, nil))
+	s.expect(s.call("GET", fmt.Sprintf("/press/news/%d", id), resident, nil), 404, "新闻不存在")
+	s.expect(s.call("DELETE", fmt.Sprintf("/press/news/%d", id), editor, nil), 404, "新闻不存在")
+}
+
+func TestPressNewsPagination(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	for i := 1; i <= 12; i++ {
+		category := 1
+		if i%3 == 0 {
+			category = 2
+		}
+		s.ok(s.call("POST", "/press/news", editor, map[string]any{"title": fmt.Sprintf("资讯%02d", i), "content": "c", "categoryId": category}))
+	}
+
+	page := s.ok(s.call("GET", "/press/newsList", resident, nil))
+	if page.total() != 12 || len(page.list()) != s.cfg.DefaultPageSize {
+		t.Fatalf("default page: total %d, %d items", page.total(), len(page.list()))
+	}
+	// Same publish date for all, so newest first by id.
+	page = s.ok(s.call("GET", "/press/newsList?pageNum=3&pageSize=5", resident, nil))
+	if len(page.list()) != 2 || page.list()[0].(map[string]any)["title"] != "资讯02" {
+		t.Fatalf("third page of five: %v", page.list())
+	}
+	page = s.ok(s.call("GET", "/press/newsList?pageNum=9&pageSize=5", resident, nil))
+	if len(page.list()) != 0 || page.total() != 12 {
+		t.Fatalf("past the end: %v", page.body)
+	}
+	page = s.ok(s.call("GET", "/press/newsList?pageSize=2", resident, nil))
+	assertGolden(t, "press_news_list", page.body)
+
+	page = s.ok(s.call("GET", "/press/category/newsList?id=2&pageSize=3", resident, nil))
+	if page.total() != 4 || len(page.list()) != 3 {
+		t.Fatalf("category 2: total %d, %d items", page.total(), len(page.list()))
+	}
+	s.expect(s.call("GET", "/press/category/newsList", resident, nil), 500, "参数错误")
+}
+
+func newsTitles(resp apiResponse) string {
+	var titles []string
+	for _, item := range resp.list() {
+		titles = append(titles, item.(map[string]any)["title"].(string))
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestPressNewsFiltersAndSort(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+	neighbor := s.login(neighborUser)
+
+	create := func(title string, category int, extra map[string]any) int {
+		body := map[string]any{"title": title, "content": "c", "categoryId": category}
+		for k, v := range extra {
+			body[k] = v
+		}
+		return s.ok(s.call("POST", "/press/news", editor, body)).id()
+	}
+	old := create("旧闻", 1, map[string]any{"tags": "社区,民生"})
+	hot := create("热门资讯", 1, map[string]any{"hot": "Y", "tags": "民生"})
+	create("置顶公告", 2, map[string]any{"top": "Y", "tags": "社区"})
+	draft := create("草稿资讯", 2, nil)
+	s.expect(s.call("POST", "/press/news", editor, map[string]any{"title": "x", "content": "c", "categoryId": 1, "top": "yes"}), 500, "参数错误")
+
+	s.db.Model(&models.PressNews{}).Where("id = ?", old).Update("publish_date", testNow.AddDate(0, 0, -10))
+	s.ok(s.call("PUT", fmt.Sprintf("/press/news/%d", draft), editor, map[string]any{"status": "1"}))
+	for i := 0; i < 2; i++ {
+		s.ok(s.call("GET", fmt.Sprintf("/press/news/%d", hot), resident, nil))
+	}
+	s.ok(s.call("PUT", fmt.Sprintf("/press/like/%d", old), resident, nil))
+	s.ok(s.call("PUT", fmt.Sprintf("/press/like/%d", old), neighbor, nil))
+	s.ok(s.call("PUT", fmt.Sprintf("/press/like/%d", draft), resident, nil))
+
+	for query, want := range map[string]string{
+		"":                      "置顶公告,草稿资讯,热门资讯,旧闻",
+		"?order=asc":            "置顶公告,旧闻,热门资讯,草稿资讯",
+		"?sort=readNum":         "置顶公告,热门资讯,草稿资讯,旧闻",
+		"?sort=likeNum":         "置顶公告,旧闻,草稿资讯,热门资讯",
+		"?status=1":             "草稿资讯",
+		"?hot=Y":                "热门资讯",
+		"?top=N":                "",
+		"?tag=社区":               "置顶公告,旧闻",
+		"?tag=民":                "",
+		"?keyword=资讯":           "草稿资讯,热门资讯",
+		"?keyword=%25":          "",
+		"?keyword=_":            "",
+		"?tag=%25":              "",
+		"?categoryId=2":         "置顶公告,草稿资讯",
+		"?startDate=2024-05-01": "置顶公告,草稿资讯,热门资讯",
+		"?endDate=2024-04-30":   "旧闻",
+		"?startDate=2024-04-21&endDate=2024-04-21": "旧闻",
+		"?tag=民生&sort=likeNum&order=asc":           "热门资讯,旧闻",
+	} {
+		if got := newsTitles(s.ok(s.call("GET", "/press/newsList"+query, resident, nil))); got != want {
+			t.Errorf("newsList%s: got %q, want %q", query, got, want)
+		}
+	}
+
+	if got := newsTitles(s.ok(s.call("GET", "/press/category/newsList?id=2&status=0", resident, nil))); got != "置顶公告" {
+		t.Errorf("category list with filter: %q", got)
+	}
+	if got := newsTitles(s.ok(s.call("GET", "/press/category/newsList?id=1&sort=likeNum", resident, nil))); got != "旧闻,热门资讯" {
+		t.Errorf("category list sorted: %q", got)
+	}
+
+	for _, bad := range []string{"?sort=title", "?order=sideways", "?startDate=2024/05/01", "?endDate=yesterday"} {
+		s.expect(s.call("GET", "/press/newsList"+bad, resident, nil), 500, "参数错误")
+	}
+}
+
+func TestPressLikesAndComments(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+	neighbor := s.login(neighborUser)
+
+	id := s.ok(s.call("POST", "/press/news", editor, map[string]any{"title": "点赞测试", "content": "c", "categoryId": 1})).id()
+	path := fmt.Sprintf("/press/like/%d", id)
+	s.expect(s.call("PUT", path, resident, nil), 200, "操作成功")
+	s.expect(s.call("PUT", path, resident, nil), 200, "已经点赞过了")
+	s.expect(s.call("PUT", path, neighbor, nil), 200, "操作成功")
+	s.expect(s.call("PUT", "/press/like/9999", resident, nil), 404, "新闻不存在")
+
+	s.ok(s.call("POST", "/comment/pressComment", resident, map[string]string{"newsId": fmt.Sprint(id), "content": "说得好", "userName": "居民甲"}))
+	s.expect(s.call("POST", "/comment/pressComment", resident, map[string]string{"newsId": "9999", "content": "x", "userName": "x"}), 404, "新闻不存在")
+
+	data := s.ok(s.call("GET", fmt.Sprintf("/press/news/%d", id), resident, nil)).data()
+	if data["likeNum"].(float64) != 2 || data["commentNum"].(float64) != 1 {
+		t.Fatalf("counters: %v", data)
+	}
+
+	comments := s.ok(s.call("GET", fmt.Sprintf("/comment/comment/%d", id), resident, nil))
+	assertGolden(t, "comment_list", comments.body)
+	commentID := int(comments.list()[0].(map[string]any)["id"].(float64))
+	s.expect(s.call("PUT", fmt.Sprintf("/comment/like/%d", commentID), neighbor, nil), 200, "操作成功")
+	s.expect(s.call("PUT", fmt.Sprintf("/comment/like/%d", commentID), neighbor, nil), 200, "已经点赞过了")
+	if n := count[models.CommentLikeRecord](t, s.db, "comment_id = ?", commentID); n != 1 {
+		t.Fatalf("want 1 comment like, got %d", n)
+	}
+}
+
+func TestNoticeCRUD(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	id := s.ok(s.call("POST", "/notice", editor, map[string]string{"title": "停水通知", "noticeContent": "周六停水", "noticeStatus": "0", "createBy": "物业"})).id()
+	s.ok(s.call("POST", "/notice", editor, map[string]string{"title": "已读公告", "noticeContent": "x", "noticeStatus": "1"}))
+
+	list := s.ok(s.call("GET", "/notice/list?noticeStatus=0", "", nil))
+	if list.total() != 1 {
+		t.Fatalf("want 1 unread notice, got %d", list.total())
+	}
+	assertGolden(t, "notice_list", list.body)
+
+	s.expect(s.call("GET", fmt.Sprintf("/notice/%d", id), "", nil), 401, "")
+	s.ok(s.call("GET", fmt.Sprintf("/notice/%d", id), resident, nil))
+	s.ok(s.call("PUT", fmt.Sprintf("/readNotice/%d", id), resident, nil))
+	if list := s.ok(s.call("GET", "/notice/list?noticeStatus=1", "", nil)); list.total() != 2 {
+		t.Fatalf("read notice should move to status 1, got %d", list.total())
+	}
+
+	s.ok(s.call("PUT", fmt.Sprintf("/notice/%d", id), editor, map[string]string{"title": "停水通知（更正）"}))
+	s.ok(s.call("DELETE", fmt.Sprintf("/notice/%d", id), editor, nil))
+	s.expect(s.call("GET", fmt.Sprintf("/notice/%d", id), resident, nil), 404, "公告不存在")
+}
+
+func TestRotationCRUD(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+
+	s.expect(s.call("GET", "/rotation/list", "", nil), 500, "参数错误")
+	id := s.ok(s.call("POST", "/rotation", editor, map[string]any{"title": "首页轮播", "picPath": "/profile/upload/image/a.png", "type": 2})).id()
+
+	list := s.ok(s.call("GET", "/rotation/list?type=2", "", nil))
+	assertGolden(t, "rotation_list", list.body)
+
+	s.ok(s.call("PUT", fmt.Sprintf("/rotation/%d", id), editor, map[string]any{"type": 3}))
+	if list := s.ok(s.call("GET", "/rotation/list?type=2", "", nil)); list.total() != 0 {
+		t.Fatalf("rotation should have moved to type 3")
+	}
+	s.ok(s.call("DELETE", fmt.Sprintf("/rotation/%d", id), editor, nil))
+	s.expect(s.call("DELETE", fmt.Sprintf("/rotation/%d", id), editor, nil), 404, "轮播图不存在")
+}
@@ -1,111 +1,167 @@
 package router
 
 import (
+	"digital-community/internal/config"
 	"digital-community/internal/handlers"
+	"digital-community/internal/metrics"
 	"digital-community/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Setup() *gin.Engine {
-	r := gin.New()
-	r.Use(gin.Recovery())
+// Setup wires the handler services into routes. Nothing here reads package
+// state, so several engines can run side by side, e.g. in tests.
+func Setup(cfg *config.Config, h *handlers.Handlers) *gin.Engine {
+	authMiddleware := middleware.AuthMiddleware(cfg.JWTSecret, h.Sessions)
+	strictStatus := middleware.StrictStatusMiddleware(cfg.StrictHTTPStatus)
 
-	r.Use(middleware.CORSMiddleware())
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.RecoveryMiddleware())
+	r.Use(middleware.CORSMiddleware(cfg.CORSAllowedOrigins))
 	r.Use(middleware.LoggerMiddleware())
-	_ = r.SetTrustedProxies([]string{"127.0.0.1", "::1"})
+	r.Use(metrics.Middleware())
+	_ = r.SetTrustedProxies(cfg.TrustedProxies)
+
+	r.Static("/profile/upload", cfg.UploadRoot)
+	r.GET("/health", h.System.Health)
+	r.GET("/ready", h.System.Ready)
+	r.GET("/version", h.System.Version)
+	if cfg.MetricsToken != "" {
+		r.GET("/metrics", middleware.StaticTokenMiddleware(cfg.MetricsToken), gin.WrapH(metrics.Handler()))
+	}
+	r.POST("/logout", strictStatus, authMiddleware, h.Users.Logout)
+
+	prodApi := r.Group(cfg.APIPrefix, strictStatus)
+	authed := prodApi.Group("", authMiddleware)
+	content := authed.Group("", middleware.RequirePermission(middleware.PermContentManage))
+	media := authed.Group("", middleware.RequirePermission(middleware.PermMediaManage))
+	admin := authed.Group("", middleware.RequirePermission(middleware.PermUserManage))
+	system := authed.Group("", middleware.RequirePermission(middleware.PermSystemManage))
+
+	// public
+	{
+		prodApi.POST("/phone/login", h.Users.PhoneLogin)
+		prodApi.POST("/login", h.Users.Login)
+		prodApi.POST("/token/refresh", h.Users.RefreshToken)
+		prodApi.GET("/smsCode", h.Users.SMSCode)
+		prodApi.GET("/password/smsCode", h.Users.PasswordSMSCode)
+		prodApi.POST("/password/verify", h.Users.PasswordVerify)
+		prodApi.POST("/password/reset", h.Users.PasswordReset)
+		prodApi.POST("/register", h.Users.Register)
+
+		prodApi.GET("/rotation/list", h.Press.RotationList)
+		prodApi.GET("/common/datacard", h.Green.CommonDataCard)
+		prodApi.GET("/common/images", h.Media.ImageList)
+		prodApi.GET("/common/files", h.Media.FileList)
+		prodApi.GET("/data/:listKey", h.Green.GreenDataSeriesByKey)
+		prodApi.GET("/notice/list", h.Press.NoticeList)
+
+		prodApi.GET("/friendly_neighborhood/list", h.Activity.FriendlyNeighborList)
+		prodApi.POST("/friendly_neighborhood/add/comment", h.Activity.FriendlyNeighborAddComment)
+		prodApi.GET("/friendly_neighborhood/:id", h.Activity.FriendlyNeighborDetail)
+
+		prodApi.GET("/activity/topList", h.Activity.ActivityTopList)
+		prodApi.GET("/activity/list", h.Activity.ActivityList)
+		prodApi.POST("/activity/search", h.Activity.ActivitySearch)
+		prodApi.GET("/activity/category/list/:id", h.Activity.ActivityCategoryList)
+		prodApi.GET("/activity/:id", h.Activity.ActivityDetail)
+	}
+
+	// any logged-in user
+	{
+		authed.GET("/press/category/list", h.Press.PressCategoryList)
+		authed.GET("/press/newsList", h.Press.PressNewsList)
+		authed.GET("/press/category/newsList", h.Press.PressCategoryNewsList)
+		authed.GET("/press/news/:id", h.Press.PressNewsDetail)
+		authed.PUT("/press/like/:id", h.Press.PressLike)
+
+		authed.POST("/comment/pressComment", h.Press.PressComment)
+		authed.GET("/comment/comment/:id", h.Press.CommentList)
+		authed.PUT("/comment/like/:id", h.Press.CommentLike)
+
+		authed.POST("/common/upload", h.Media.Upload)
+
+		authed.GET("/question/list", h.Green.GreenQuestionList)
+		authed.GET("/question/questionList/:id/:level", h.Green.QuestionQuestionList)
+		authed.POST("/question/savePaper", h.Green.QuestionSavePaper)
+
+		authed.GET("/data/list", h.Green.GreenDataSeriesList)
+
+		authed.GET("/notice/:id", h.Press.NoticeDetail)
+		authed.PUT("/readNotice/:id", h.Press.ReadNotice)
+
+		// Residents may edit and delete their own posts; the handlers let
+		// content managers through for any post.
+		authed.POST("/friendly_neighborhood", h.Activity.FriendlyNeighborCreate)
+		authed.PUT("/friendly_neighborhood/:id", h.Activity.FriendlyNeighborUpdate)
+		authed.DELETE("/friendly_neighborhood/:id", h.Activity.FriendlyNeighborDelete)
 
-	r.Static("/profile", "./profile")
-	r.POST("/logout", handlers.Logout)
+		authed.GET("/registration/list", h.Activity.RegistrationList)
+		authed.POST("/registration", h.Activity.Registration)
+		authed.PUT("/checkin/:id", h.Activity.Checkin)
+		authed.PUT("/registration/comment/:id", h.Activity.RegistrationComment)
+
+		authed.GET("/search", h.Search.SearchAll)
+
+		authed.GET("/user/getUserInfo", h.Users.GetUserInfo)
+		authed.PUT("/user/updateUserInfo", h.Users.UpdateUserInfo)
+		authed.PUT("/user/resetPwd", h.Users.ResetPwd)
+		authed.POST("/user/logoutAll", h.Users.LogoutAll)
+	}
+
+	// editors and admins
+	{
+		content.POST("/rotation", h.Press.RotationCreate)
+		content.PUT("/rotation/:id", h.Press.RotationUpdate)
+		content.DELETE("/rotation/:id", h.Press.RotationDelete)
+
+		content.POST("/press/category", h.Press.PressCategoryCreate)
+		content.PUT("/press/category/:id", h.Press.PressCategoryUpdate)
+		content.DELETE("/press/category/:id", h.Press.PressCategoryDelete)
+
+		content.POST("/press/news", h.Press.PressNewsCreate)
+		content.PUT("/press/news/:id", h.Press.PressNewsUpdate)
+		content.DELETE("/press/news/:id", h.Press.PressNewsDelete)
+
+		content.POST("/common/datacard", h.Green.GreenDataCardCreate)
+		content.PUT("/common/datacard/:id", h.Green.GreenDataCardUpdate)
+		content.DELETE("/common/datacard/:id", h.Green.GreenDataCardDelete)
+
+		content.POST("/question", h.Green.GreenQuestionCreate)
+		content.PUT("/question/:id", h.Green.GreenQuestionUpdate)
+		content.DELETE("/question/:id", h.Green.GreenQuestionDelete)
+
+		content.POST("/data/list", h.Green.GreenDataSeriesCreate)
+		content.PUT("/data/list/:id", h.Green.GreenDataSeriesUpdate)
+		content.DELETE("/data/list/:id", h.Green.GreenDataSeriesDelete)
+
+		content.POST("/notice", h.Press.NoticeCreate)
+		content.PUT("/notice/:id", h.Press.NoticeUpdate)
+		content.DELETE("/notice/:id", h.Press.NoticeDelete)
+
+		content.POST("/activity", h.Activity.ActivityCreate)
+		content.PUT("/activity/:id", h.Activity.ActivityUpdate)
+		content.DELETE("/activity/:id", h.Activity.ActivityDelete)
+
+		media.DELETE("/common/images", h.Media.ImageDelete)
+		media.DELETE("/common/files", h.Media.FileDelete)
+	}
 
-	prodApi := r.Group("/prod-api/api")
+	// admins only
 	{
-		prodApi.POST("/phone/login", handlers.PhoneLogin)
-		prodApi.POST("/login", handlers.Login)
-		prodApi.GET("/smsCode", handlers.SMSCode)
-		prodApi.POST("/register", handlers.Register)
-
-		prodApi.GET("/rotation/list", handlers.RotationList)
-		prodApi.POST("/rotation", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.RotationCreate)
-		prodApi.PUT("/rotation/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.RotationUpdate)
-		prodApi.DELETE("/rotation/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.RotationDelete)
-
-		prodApi.GET("/press/category/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressCategoryList)
-		prodApi.POST("/press/category", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressCategoryCreate)
-		prodApi.PUT("/press/category/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressCategoryUpdate)
-		prodApi.DELETE("/press/category/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressCategoryDelete)
-
-		prodApi.GET("/press/newsList", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressNewsList)
-		prodApi.POST("/press/news", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressNewsCreate)
-		prodApi.PUT("/press/news/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressNewsUpdate)
-		prodApi.DELETE("/press/news/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressNewsDelete)
-		prodApi.GET("/press/category/newsList", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressCategoryNewsList)
-		prodApi.GET("/press/news/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressNewsDetail)
-		prodApi.PUT("/press/like/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressLike)
-
-		prodApi.POST("/comment/pressComment", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.PressComment)
-		prodApi.GET("/comment/comment/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.CommentList)
-		prodApi.PUT("/comment/like/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.CommentLike)
-
-		prodApi.POST("/common/upload", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.Upload)
-		prodApi.GET("/common/datacard", handlers.CommonDataCard)
-		prodApi.POST("/common/datacard", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataCardCreate)
-		prodApi.PUT("/common/datacard/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataCardUpdate)
-		prodApi.DELETE("/common/datacard/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataCardDelete)
-		prodApi.GET("/common/images", handlers.ImageList)
-		prodApi.DELETE("/common/images", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ImageDelete)
-		prodApi.GET("/common/files", handlers.FileList)
-		prodApi.DELETE("/common/files", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.FileDelete)
-
-		prodApi.GET("/question/questionList/:id/:level", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.QuestionQuestionList)
-		prodApi.POST("/question/savePaper", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.QuestionSavePaper)
-		prodApi.GET("/question/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenQuestionList)
-		prodApi.POST("/question", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenQuestionCreate)
-		prodApi.PUT("/question/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenQuestionUpdate)
-		prodApi.DELETE("/question/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenQuestionDelete)
-
-		prodApi.GET("/data/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataSeriesList)
-		prodApi.GET("/data/:listKey", handlers.GreenDataSeriesByKey)
-		prodApi.POST("/data/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataSeriesCreate)
-		prodApi.PUT("/data/list/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataSeriesUpdate)
-		prodApi.DELETE("/data/list/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GreenDataSeriesDelete)
-
-		prodApi.GET("/notice/list", handlers.NoticeList)
-		prodApi.POST("/notice", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.NoticeCreate)
-		prodApi.PUT("/notice/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.NoticeUpdate)
-		prodApi.DELETE("/notice/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.NoticeDelete)
-		prodApi.GET("/notice/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.NoticeDetail)
-		prodApi.PUT("/readNotice/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ReadNotice)
-
-		prodApi.GET("/friendly_neighborhood/list", handlers.FriendlyNeighborList)
-		prodApi.POST("/friendly_neighborhood", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.FriendlyNeighborCreate)
-		prodApi.PUT("/friendly_neighborhood/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.FriendlyNeighborUpdate)
-		prodApi.DELETE("/friendly_neighborhood/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.FriendlyNeighborDelete)
-		prodApi.POST("/friendly_neighborhood/add/comment", handlers.FriendlyNeighborAddComment)
-		prodApi.GET("/friendly_neighborhood/:id", handlers.FriendlyNeighborDetail)
-
-		prodApi.GET("/activity/topList", handlers.ActivityTopList)
-		prodApi.GET("/activity/list", handlers.ActivityList)
-		prodApi.POST("/activity/search", handlers.ActivitySearch)
-		prodApi.GET("/activity/category/list/:id", handlers.ActivityCategoryList)
-		prodApi.GET("/activity/:id", handlers.ActivityDetail)
-		prodApi.POST("/activity", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ActivityCreate)
-		prodApi.PUT("/activity/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ActivityUpdate)
-		prodApi.DELETE("/activity/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ActivityDelete)
-
-		prodApi.GET("/registration/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.RegistrationList)
-		prodApi.POST("/registration", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.Registration)
-		prodApi.PUT("/checkin/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.Checkin)
-		prodApi.PUT("/registration/comment/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.RegistrationComment)
-
-		prodApi.GET("/user/list", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.UserList)
-		prodApi.GET("/user/getUserInfo", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.GetUserInfo)
-		prodApi.POST("/user", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.UserCreate)
-		prodApi.PUT("/user/updateUserInfo", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.UpdateUserInfo)
-		prodApi.PUT("/user/resetPwd", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.ResetPwd)
-		prodApi.PUT("/user/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.UserUpdate)
-		prodApi.DELETE("/user/:id", middleware.AuthMiddleware("digital-community-secret-key-2024"), handlers.UserDelete)
+		admin.GET("/user/list", h.Users.UserList)
+		admin.POST("/user", h.Users.UserCreate)
+		admin.PUT("/user/:id", h.Users.UserUpdate)
+		admin.DELETE("/user/:id", h.Users.UserDelete)
+		admin.PUT("/user/:id/forceLogout", h.Users.ForceLogout)
+		admin.PUT("/user/:id/unlock", h.Users.UserUnlock)
+
+		system.POST("/backup", h.System.BackupCreate)
+		system.GET("/backup/list", h.System.BackupList)
+		system.GET("/backup/:name", h.System.BackupDownload)
 	}
 
+	serveOpenAPI(r, cfg)
 	return r
 }
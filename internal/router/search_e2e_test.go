@@ -0,0 +1,147 @@
+package router_test
+
+import (
+	"digital-community/internal/models"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func (s *testServer) search(token, keyword, types string) apiResponse {
+	s.t.Helper()
+	q := url.Values{"keyword": {keyword}}
+	if types != "" {
+		q.Set("type", types)
+	}
+	return s.call("GET", "/search?"+q.Encode(), token, nil)
+}
+
+func hitTypes(resp apiResponse) []string {
+	var types []string
+	for _, item := range resp.list() {
+		types = append(types, item.(map[string]any)["type"].(string))
+	}
+	return types
+}
+
+// The same expectations hold with and without -tags sqlite_fts5: four-rune
+// keywords go through the FTS5 index when it exists, two-rune ones always
+// use the LIKE fallback.
+func TestUnifiedSearch(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.login(editorUser)
+	resident := s.login(residentUser)
+
+	newsID := s.ok(s.call("POST", "/press/news", editor, map[string]any{
+		"title":      "社区垃圾分类新规",
+		"content":    "<p>本周起实行<b>垃圾分类</b>，请居民配合</p>",
+		"categoryId": 1,
+	})).id()
+	s.ok(s.call("POST", "/notice", editor, map[string]string{"title": "垃圾清运时间调整", "noticeContent": "周末暂停清运", "noticeStatus": "0"}))
+	activityID := s.ok(s.call("POST", "/activity", editor, newActivity("垃圾分类宣传活动", 20, "0"))).id()
+	s.ok(s.call("POST", "/friendly_neighborhood", resident, map[string]any{"content": "<script>楼下垃圾桶满了</script>", "userId": 3, "nickName": "居民甲"}))
+
+	found := s.ok(s.search(resident, "垃圾分类", ""))
+	if found.total() != 2 || strings.Join(hitTypes(found), ",") != "news,activity" && strings.Join(hitTypes(found), ",") != "activity,news" {
+		t.Fatalf("垃圾分类: %v", found.body)
+	}
+	assertGolden(t, "search_results", shapeOf(found.body))
+	for _, item := range found.list() {
+		hit := item.(map[string]any)
+		if !strings.Contains(hit["title"].(string), "<em>") {
+			t.Fatalf("title not highlighted: %v", hit)
+		}
+		if hit["type"] == "news" && (strings.Contains(hit["snippet"].(string), "<p>") || !strings.Contains(hit["snippet"].(string), "<em>")) {
+			t.Fatalf("news snippet should be plain text with highlights: %v", hit)
+		}
+	}
+
+	if only := s.ok(s.search(resident, "垃圾分类", "activity")); only.total() != 1 || hitTypes(only)[0] != "activity" {
+		t.Fatalf("type filter: %v", only.body)
+	}
+	if all := s.ok(s.search(resident, "垃圾", "")); all.total() != 4 {
+		t.Fatalf("short keyword should match every kind: %v", all.body)
+	}
+	neighbor := s.ok(s.search(resident, "垃圾", "neighbor")).list()[0].(map[string]any)
+	if !strings.Contains(neighbor["snippet"].(string), "&lt;script&gt;") {
+		t.Fatalf("neighbor snippet must be escaped: %v", neighbor)
+	}
+	if both := s.ok(s.search(resident, "垃圾 清运", "")); both.total() != 1 || hitTypes(both)[0] != "notice" {
+		t.Fatalf("every term must match: %v", both.body)
+	}
+
+	// Edits and deletes reach the index.
+	s.ok(s.call("PUT", fmt.Sprintf("/activity/%d", activityID), editor, map[string]any{"title": "环保宣传活动", "content": "旧物回收"}))
+	s.ok(s.call("DELETE", fmt.Sprintf("/press/news/%d", newsID), editor, nil))
+	if gone := s.ok(s.search(resident, "垃圾分类", "")); gone.total() != 0 {
+		t.Fatalf("stale results: %v", gone.body)
+	}
+	if renamed := s.ok(s.search(resident, "环保宣传", "")); renamed.total() != 1 {
+		t.Fatalf("updated title not searchable: %v", renamed.body)
+	}
+
+	s.expect(s.search(resident, " ", ""), 500, "关键词不能为空")
+	s.expect(s.search(resident, "垃圾", "news,video"), 500, "类型参数错误")
+	s.expect(s.search("", "垃圾", ""), 401, "")
+}
+
+// The LIKE fallback counts and pages in SQL, so results past the first few
+// hundred rows stay reachable.
+func TestSearchFallbackPaging(t *testing.T) {
+	s := newTestServer(t)
+	resident := s.login(residentUser)
+
+	posts := make([]models.FriendlyNeighbor, 205)
+	for i := range posts {
+		posts[i] = models.FriendlyNeighbor{UserId: 3, NickName: "居民甲", Content: fmt.Sprintf("第%d号停车位出租", i+1)}
+	}
+	// A title hit ranks first even though it is the oldest post.
+	posts[0].NickName = "停车管理员"
+	if err := s.db.Create(&posts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first := s.ok(s.call("GET", "/search?"+url.Values{"keyword": {"停车"}, "pageSize": {"10"}}.Encode(), resident, nil))
+	if first.total() != 205 || len(first.list()) != 10 {
+		t.Fatalf("first page: total %d, %d rows", first.total(), len(first.list()))
+	}
+	if top := first.list()[0].(map[string]any); int(top["id"].(float64)) != int(posts[0].ID) {
+		t.Fatalf("title hit should rank first: %v", top)
+	}
+	last := s.ok(s.call("GET", "/search?"+url.Values{"keyword": {"停车"}, "pageSize": {"10"}, "pageNum": {"21"}}.Encode(), resident, nil))
+	if last.total() != 205 || len(last.list()) != 5 {
+		t.Fatalf("last page: total %d, %d rows", last.total(), len(last.list()))
+	}
+}
+
+// The LIKE fallback matches terms literally and regardless of case, the same
+// way the score counts them.
+func TestSearchFallbackMatchesLiterally(t *testing.T) {
+	s := newTestServer(t)
+	resident := s.login(residentUser)
+
+	posts := []models.FriendlyNeighbor{
+		{UserId: 3, NickName: "居民甲", Content: "楼道WIFI信号满格，满意度100%"},
+		{UserId: 3, NickName: "居民乙", Content: "快递柜_已满"},
+		{UserId: 3, NickName: "居民丙", Content: "周末停车位紧张"},
+	}
+	if err := s.db.Create(&posts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		keyword string
+		want    uint
+	}{
+		{"%", posts[0].ID},
+		{"_", posts[1].ID},
+		{"wi", posts[0].ID},
+	}
+	for _, tc := range cases {
+		found := s.ok(s.search(resident, tc.keyword, "neighbor"))
+		if found.total() != 1 || uint(found.list()[0].(map[string]any)["id"].(float64)) != tc.want {
+			t.Fatalf("%q: %v", tc.keyword, found.body)
+		}
+	}
+}
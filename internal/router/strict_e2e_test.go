@@ -0,0 +1,46 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Strict mode takes the status from the class the handler attached, falling
+// back to the body's code, never from the message text.
+func TestStrictResponseMode(t *testing.T) {
+	s := newTestServer(t)
+	s.strict = true
+	resident := s.login(residentUser)
+
+	cases := []struct {
+		name      string
+		resp      apiResponse
+		status    int
+		errorCode string
+	}{
+		{"bad credentials", s.call("POST", "/login", "", map[string]string{"userName": residentUser, "password": "wrong"}),
+			http.StatusUnauthorized, "INVALID_CREDENTIALS"},
+		{"bad type filter", s.call("GET", "/search?keyword=x&type=video", resident, nil), http.StatusBadRequest, "INVALID_PARAMS"},
+		{"missing row", s.call("GET", "/press/news/999", resident, nil), http.StatusNotFound, "NOT_FOUND"},
+		{"no token", s.call("GET", "/user/getUserInfo", "", nil), http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"no permission", s.call("POST", "/notice", resident, map[string]string{"title": "停水通知"}), http.StatusForbidden, "FORBIDDEN"},
+	}
+	for _, tc := range cases {
+		if tc.resp.status != tc.status || tc.resp.body["errorCode"] != tc.errorCode {
+			t.Errorf("%s: got %d %v, want %d %s", tc.name, tc.resp.status, tc.resp.body["errorCode"], tc.status, tc.errorCode)
+		}
+	}
+
+	invalid := s.call("POST", "/register", "", map[string]string{"password": testPassword})
+	if invalid.status != http.StatusBadRequest || invalid.body["errorCode"] != "INVALID_PARAMS" {
+		t.Fatalf("binding failure: %d %v", invalid.status, invalid.body)
+	}
+	fields, _ := invalid.body["errors"].([]any)
+	if len(fields) == 0 || fields[0].(map[string]any)["field"] != "userName" {
+		t.Fatalf("field errors should use JSON names: %v", invalid.body)
+	}
+
+	if ok := s.ok(s.call("GET", "/user/getUserInfo", resident, nil)); ok.status != http.StatusOK || ok.body["errorCode"] != nil {
+		t.Fatalf("success must stay 200 without errorCode: %v", ok.body)
+	}
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// swagger.html replaces the index page shipped with the Swagger UI assets,
+// which points at the petstore demo instead of our document.
+//
+//go:embed swagger.html
+var swaggerIndex []byte
+
+func mountSwaggerUI(r *gin.Engine, base string) {
+	assets := http.StripPrefix(base, http.FileServer(swaggerFiles.HTTP))
+	r.GET(base, func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, base+"/")
+	})
+	r.GET(base+"/*filepath", func(c *gin.Context) {
+		switch c.Param("filepath") {
+		case "/", "/index.html":
+			c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerIndex)
+		default:
+			assets.ServeHTTP(c.Writer, c.Request)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package seed
+
+import (
+	"digital-community/internal/auth"
+	"digital-community/internal/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	loadTestUsers      = 500
+	loadTestNews       = 2000
+	loadTestActivities = 200
+	loadTestNeighbors  = 1000
+
+	loadTestUserPrefix  = "load"
+	loadTestTitlePrefix = "压测"
+)
+
+func seedLoadTest(db *gorm.DB, uploadRoot string) error {
+	if err := seedDemo(db, uploadRoot); err != nil {
+		return err
+	}
+	images, err := prepareSeedImages(uploadRoot)
+	if err != nil {
+		return err
+	}
+
+	// Every load-test account shares one hash; bcrypt per row would dominate
+	// the run time.
+	hashed, err := auth.HashPassword("123456")
+	if err != nil {
+		return err
+	}
+	err = topUp(db, "user_name", loadTestUserPrefix, loadTestUsers, func(i int) models.User {
+		return models.User{
+			UserName: fmt.Sprintf("%s%04d", loadTestUserPrefix, i+1),
+			NickName: fmt.Sprintf("压测用户%d", i+1),
+			PassWord: hashed,
+			Phone:    fmt.Sprintf("139%08d", i+1),
+			Sex:      fmt.Sprint(i % 2),
+			Avatar:   pickImage(images, i),
+			Status:   models.UserStatusNormal,
+			DelFlag:  models.DelFlagExists,
+			Role:     models.RoleResident,
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("load-test users: %w", err)
+	}
+
+	pressCategories, err := categoryIDs[models.PressCategory](db)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = topUp(db, "title", loadTestTitlePrefix, loadTestNews, func(i int) models.PressNews {
+		return models.PressNews{
+			Title:       fmt.Sprintf("%s资讯%04d", loadTestTitlePrefix, i+1),
+			SubTitle:    "压测数据",
+			Content:     fmt.Sprintf("<p>压测资讯正文%d。</p>", i+1),
+			ImageUrls:   pickImage(images, i),
+			CategoryId:  pressCategories[i%len(pressCategories)],
+			Author:      "压测",
+			Source:      "数字社区",
+			ViewCount:   i % 1000,
+			LikeNum:     i % 50,
+			Type:        fmt.Sprint(i%3 + 1),
+			Top:         []string{"N", "Y"}[boolIndex(i%100 == 0)],
+			Hot:         []string{"N", "Y"}[boolIndex(i%10 == 0)],
+			Tags:        "社区,压测",
+			Status:      "0",
+			PublishDate: now.Add(time.Duration(-i) * time.Hour),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("load-test news: %w", err)
+	}
+
+	activityCategories, err := categoryIDs[models.ActivityCategory](db)
+	if err != nil {
+		return err
+	}
+	err = topUp(db, "title", loadTestTitlePrefix, loadTestActivities, func(i int) models.Activity {
+		start := now.Add(time.Duration(i%60-30) * 24 * time.Hour)
+		return models.Activity{
+			Title:      fmt.Sprintf("%s活动%04d", loadTestTitlePrefix, i+1),
+			Content:    "压测活动内容",
+			PicPath:    pickImage(images, i),
+			CategoryId: activityCategories[i%len(activityCategories)],
+			StartDate:  start,
+			EndDate:    start.Add(2 * time.Hour),
+			Address:    "社区活动中心",
+			TotalCount: 100,
+			IsTop:      "0",
+			Status:     "0",
+			CreateBy:   "压测",
+			CreateTime: now.Format("2006-01-02 15:04:05"),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("load-test activities: %w", err)
+	}
+
+	err = topUp(db, "nick_name", loadTestTitlePrefix, loadTestNeighbors, func(i int) models.FriendlyNeighbor {
+		return models.FriendlyNeighbor{
+			UserId:     1,
+			NickName:   fmt.Sprintf("%s邻居%d", loadTestTitlePrefix, i%loadTestUsers+1),
+			UserImgUrl: pickImage(images, i),
+			Content:    fmt.Sprintf("压测友邻动态%d", i+1),
+			ImgUrl:     pickImage(images, i+7),
+			LikeNum:    i % 20,
+			CreateTime: now.Add(time.Duration(-i) * time.Minute).Format("2006-01-02 15:04:05"),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("load-test neighbors: %w", err)
+	}
+	return nil
+}
+
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+// Package seed loads named fixture sets into the database. Every set only
+// fills in what is missing, so rerunning a set is safe.
+package seed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SetDemo     = "demo"
+	SetEmpty    = "empty"
+	SetLoadTest = "load-test"
+)
+
+var sets = map[string]func(db *gorm.DB, uploadRoot string) error{
+	// empty only creates the lookup rows the app needs to be usable: no
+	// accounts, no content, no copied images.
+	SetEmpty: seedEmpty,
+	// demo is the test01/123456 admin plus sample content.
+	SetDemo: seedDemo,
+	// load-test is demo plus bulk users and content for benchmarking.
+	SetLoadTest: seedLoadTest,
+}
+
+func Sets() []string {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run loads set inside one transaction. Images for the demo content are
+// copied into uploadRoot/image/seed.
+func Run(db *gorm.DB, set, uploadRoot string) error {
+	fn, ok := sets[set]
+	if !ok {
+		return fmt.Errorf("unknown seed set %q (want one of: %s)", set, strings.Join(Sets(), ", "))
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return fn(tx, uploadRoot)
+	})
+}
+
+func seedEmpty(db *gorm.DB, _ string) error {
+	if err := seedPressCategories(db); err != nil {
+		return fmt.Errorf("press categories: %w", err)
+	}
+	if err := seedActivityCategories(db); err != nil {
+		return fmt.Errorf("activity categories: %w", err)
+	}
+	return nil
+}
+
+// topUp creates rows whose column starts with prefix until there are target
+// of them, so a partially applied run is completed rather than duplicated.
+func topUp[T any](db *gorm.DB, column, prefix string, target int, build func(i int) T) error {
+	var count int64
+	if err := db.Model(new(T)).Where(column+" LIKE ?", prefix+"%").Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) >= target {
+		return nil
+	}
+	items := make([]T, 0, target-int(count))
+	for i := int(count); i < target; i++ {
+		items = append(items, build(i))
+	}
+	return db.CreateInBatches(items, 200).Error
+}
+
+func categoryIDs[T any](db *gorm.DB) ([]int, error) {
+	var ids []int
+	if err := db.Model(new(T)).Order("id asc").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []int{0}, nil
+	}
+	return ids, nil
+}
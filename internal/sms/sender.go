@@ -0,0 +1,99 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type Sender interface {
+	Send(ctx context.Context, phone, code string) error
+}
+
+// LogSender is meant for development: codes are written to the process log or,
+// when Path is set, appended to a file.
+type LogSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *LogSender) Send(ctx context.Context, phone, code string) error {
+	if s.Path == "" {
+		log.Printf("sms phone=%s code=%s", phone, code)
+		return nil
+	}
+	line := fmt.Sprintf("%s sms phone=%s code=%s\n", time.Now().Format("2006-01-02 15:04:05"), phone, code)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line)
+	return err
+}
+
+// HTTPSender posts {"phone","code","template"} as JSON to an SMS gateway.
+// Any non-2xx response is treated as a failed delivery.
+type HTTPSender struct {
+	URL      string
+	APIKey   string
+	Template string
+	Client   *http.Client
+}
+
+func (s *HTTPSender) Send(ctx context.Context, phone, code string) error {
+	body, err := json.Marshal(map[string]string{
+		"phone":    phone,
+		"code":     code,
+		"template": s.Template,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms gateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+func NewSender(provider, logFile, gatewayURL, apiKey, template string) (Sender, error) {
+	switch provider {
+	case "", "log":
+		return &LogSender{Path: logFile}, nil
+	case "http":
+		if gatewayURL == "" {
+			return nil, fmt.Errorf("sms provider http requires a gateway url")
+		}
+		return &HTTPSender{URL: gatewayURL, APIKey: apiKey, Template: template}, nil
+	default:
+		return nil, fmt.Errorf("unknown sms provider %q", provider)
+	}
+}
@@ -0,0 +1,59 @@
+package sms_test
+
+import (
+	"context"
+	"digital-community/internal/sms"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSender(t *testing.T) {
+	var got struct {
+		auth, contentType string
+		body              map[string]string
+	}
+	status := http.StatusOK
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.auth = r.Header.Get("Authorization")
+		got.contentType = r.Header.Get("Content-Type")
+		got.body = nil
+		if err := json.NewDecoder(r.Body).Decode(&got.body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer gateway.Close()
+
+	sender, err := sms.NewSender("http", "", gateway.URL, "gateway-key", "verify_code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), "13800000003", "1234"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got.auth != "Bearer gateway-key" || got.contentType != "application/json" {
+		t.Fatalf("headers: %+v", got)
+	}
+	want := map[string]string{"phone": "13800000003", "code": "1234", "template": "verify_code"}
+	for k, v := range want {
+		if got.body[k] != v {
+			t.Fatalf("body %v, want %v", got.body, want)
+		}
+	}
+
+	status = http.StatusBadGateway
+	if err := sender.Send(context.Background(), "13800000003", "1234"); err == nil {
+		t.Fatal("a non-2xx gateway response must fail the send")
+	}
+}
+
+func TestNewSenderRejectsMisconfiguration(t *testing.T) {
+	if _, err := sms.NewSender("http", "", "", "", "verify_code"); err == nil {
+		t.Fatal("http provider without a gateway url should fail")
+	}
+	if _, err := sms.NewSender("carrier-pigeon", "", "", "", ""); err == nil {
+		t.Fatal("unknown provider should fail")
+	}
+}
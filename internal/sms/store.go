@@ -0,0 +1,181 @@
+package sms
+
+import (
+	"crypto/subtle"
+	"digital-community/internal/models"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CodeStore keeps verification codes keyed by purpose and phone. Verify
+// consumes the code when it matches, so each code is accepted exactly once;
+// failed attempts are counted and the code is discarded once maxAttempts is
+// reached.
+type CodeStore interface {
+	Set(key, code string, ttl time.Duration) error
+	Verify(key, code string, maxAttempts int) (bool, error)
+	Delete(key string) error
+}
+
+type codeRecord struct {
+	code      string
+	expiresAt time.Time
+	attempts  int
+}
+
+type MemoryCodeStore struct {
+	now  func() time.Time
+	mu   sync.Mutex
+	data map[string]codeRecord
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewMemoryCodeStore expires codes by now; nil means time.Now.
+func NewMemoryCodeStore(sweepInterval time.Duration, now func() time.Time) *MemoryCodeStore {
+	if now == nil {
+		now = time.Now
+	}
+	s := &MemoryCodeStore{now: now, data: map[string]codeRecord{}, stop: make(chan struct{})}
+	if sweepInterval > 0 {
+		go s.janitor(sweepInterval)
+	}
+	return s
+}
+
+func (s *MemoryCodeStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(s.now())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *MemoryCodeStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, rec := range s.data {
+		if now.After(rec.expiresAt) {
+			delete(s.data, key)
+		}
+	}
+}
+
+func (s *MemoryCodeStore) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *MemoryCodeStore) Set(key, code string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = codeRecord{code: code, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryCodeStore) Verify(key, code string, maxAttempts int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.data[key]
+	if !ok {
+		return false, nil
+	}
+	if s.now().After(rec.expiresAt) {
+		delete(s.data, key)
+		return false, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(rec.code), []byte(code)) != 1 {
+		rec.attempts++
+		if maxAttempts > 0 && rec.attempts >= maxAttempts {
+			delete(s.data, key)
+		} else {
+			s.data[key] = rec
+		}
+		return false, nil
+	}
+	delete(s.data, key)
+	return true, nil
+}
+
+func (s *MemoryCodeStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// DBCodeStore persists codes in the sms_codes table so they survive restarts
+// and are shared by every API instance pointing at the same database.
+type DBCodeStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewDBCodeStore expires codes by now; nil means time.Now.
+func NewDBCodeStore(db *gorm.DB, now func() time.Time) *DBCodeStore {
+	if now == nil {
+		now = time.Now
+	}
+	return &DBCodeStore{db: db, now: now}
+}
+
+func (s *DBCodeStore) Set(key, code string, ttl time.Duration) error {
+	now := s.now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("code_key = ? OR expires_at < ?", key, now).Delete(&models.SMSCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SMSCode{CodeKey: key, Code: code, ExpiresAt: now.Add(ttl)}).Error
+	})
+}
+
+// Verify claims an attempt with a conditional UPDATE before comparing, so
+// parallel guesses on MySQL or PostgreSQL cannot all pass the cap on a stale
+// count. A match deletes the row; only the request whose DELETE removed it
+// wins, so a code cannot be used twice.
+func (s *DBCodeStore) Verify(key, code string, maxAttempts int) (bool, error) {
+	var rec models.SMSCode
+	if err := s.db.Where("code_key = ?", key).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if s.now().After(rec.ExpiresAt) {
+		return false, s.db.Delete(&models.SMSCode{}, rec.ID).Error
+	}
+
+	attempt := s.db.Model(&models.SMSCode{}).Where("id = ?", rec.ID)
+	if maxAttempts > 0 {
+		attempt = attempt.Where("attempts < ?", maxAttempts)
+	}
+	res := attempt.UpdateColumn("attempts", gorm.Expr("attempts + ?", 1))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Out of attempts, or another request already consumed the code.
+		return false, s.db.Delete(&models.SMSCode{}, rec.ID).Error
+	}
+
+	if subtle.ConstantTimeCompare([]byte(rec.Code), []byte(code)) == 1 {
+		res := s.db.Delete(&models.SMSCode{}, rec.ID)
+		return res.Error == nil && res.RowsAffected == 1, res.Error
+	}
+	if maxAttempts > 0 {
+		// Drop the code as soon as the last attempt has failed.
+		return false, s.db.Where("id = ? AND attempts >= ?", rec.ID, maxAttempts).Delete(&models.SMSCode{}).Error
+	}
+	return false, nil
+}
+
+func (s *DBCodeStore) Delete(key string) error {
+	return s.db.Where("code_key = ?", key).Delete(&models.SMSCode{}).Error
+}
@@ -0,0 +1,64 @@
+package sms_test
+
+import (
+	"digital-community/internal/config"
+	"digital-community/internal/sms"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newDBCodeStore(t *testing.T) *sms.DBCodeStore {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.CloseDB(db) })
+	return sms.NewDBCodeStore(db, nil)
+}
+
+func TestCodeStoresConsumeMatchingCode(t *testing.T) {
+	memory := sms.NewMemoryCodeStore(0, nil)
+	defer memory.Close()
+	stores := map[string]sms.CodeStore{"memory": memory, "database": newDBCodeStore(t)}
+	for name, store := range stores {
+		if err := store.Set("login:13800000003", "1234", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := store.Verify("login:13800000003", "1234", 5); err != nil || !ok {
+			t.Fatalf("%s: first use = %v, %v", name, ok, err)
+		}
+		if ok, _ := store.Verify("login:13800000003", "1234", 5); ok {
+			t.Fatalf("%s: a code must only be accepted once", name)
+		}
+	}
+}
+
+// Wrong guesses racing each other must not get past maxAttempts: once the cap
+// is spent the right code is refused as well.
+func TestDBCodeStoreCapsParallelGuesses(t *testing.T) {
+	store := newDBCodeStore(t)
+	if err := store.Set("login:13800000003", "1234", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := store.Verify("login:13800000003", "0000", 5); ok {
+				t.Error("wrong code accepted")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok, _ := store.Verify("login:13800000003", "1234", 5); ok {
+		t.Fatal("code still usable after the attempt cap was spent")
+	}
+}
@@ -0,0 +1,61 @@
+package sms
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle is a sliding-window limiter allowing at most Limit events per key
+// within Window.
+type Throttle struct {
+	Limit  int
+	Window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// NewThrottle measures windows with now; nil means time.Now.
+func NewThrottle(limit int, window time.Duration, now func() time.Time) *Throttle {
+	if now == nil {
+		now = time.Now
+	}
+	return &Throttle{Limit: limit, Window: window, now: now, events: map[string][]time.Time{}}
+}
+
+func (t *Throttle) Allow(key string) (bool, time.Duration) {
+	if t == nil || t.Limit <= 0 {
+		return true, 0
+	}
+	now := t.now()
+	cutoff := now.Add(-t.Window)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	kept := t.events[key][:0]
+	for _, ts := range t.events[key] {
+		if ts.After(cutoff) {
+			kept = append(kept, ts)
+		}
+	}
+	if len(kept) >= t.Limit {
+		t.events[key] = kept
+		return false, kept[0].Add(t.Window).Sub(now)
+	}
+	t.events[key] = append(kept, now)
+
+	if len(t.events) > 10000 {
+		t.sweep(cutoff)
+	}
+	return true, 0
+}
+
+func (t *Throttle) sweep(cutoff time.Time) {
+	for key, list := range t.events {
+		if len(list) == 0 || !list[len(list)-1].After(cutoff) {
+			delete(t.events, key)
+		}
+	}
+}